@@ -13,11 +13,18 @@ import (
 )
 
 type Album struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	OtherName *string `json:"otherName"`
 
 	Year *int64 `json:"year"`
 
+	// ReleaseDate and OriginalReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD
+	ReleaseDate         *string `json:"releaseDate"`
+	OriginalReleaseDate *string `json:"originalReleaseDate"`
+
+	ReleaseType types.ReleaseType `json:"releaseType"`
+
 	CoverArt types.Images `json:"coverArt"`
 
 	Artists []ArtistInfo `json:"artists"`
@@ -43,14 +50,18 @@ func ConvertDBAlbum(c pyrin.Context, album database.Album) Album {
 		}
 	}
 	return Album{
-		Id:       album.Id,
-		Name:     album.Name,
-		Year:     ConvertSqlNullInt64(album.Year),
-		CoverArt: ConvertAlbumCoverURL(c, album.Id, album.CoverArt),
-		Artists:  allArtists,
-		Tags:     utils.SplitString(album.Tags.String),
-		Created:  album.Created,
-		Updated:  album.Updated,
+		Id:                  album.Id,
+		Name:                album.Name,
+		OtherName:           ConvertSqlNullString(album.OtherName),
+		Year:                ConvertSqlNullInt64(album.Year),
+		ReleaseDate:         ConvertSqlNullString(album.ReleaseDate),
+		OriginalReleaseDate: ConvertSqlNullString(album.OriginalReleaseDate),
+		ReleaseType:         album.ReleaseType,
+		CoverArt:            ConvertAlbumCoverURL(c, album.Id, album.CoverArt),
+		Artists:             allArtists,
+		Tags:                utils.SplitString(album.Tags.String),
+		Created:             album.Created,
+		Updated:             album.Updated,
 	}
 }
 
@@ -18,8 +18,9 @@ type ArtistInfo struct {
 }
 
 type Artist struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	OtherName *string `json:"otherName"`
 
 	Picture types.Images `json:"picture"`
 
@@ -31,12 +32,13 @@ type Artist struct {
 
 func ConvertDBArtist(c pyrin.Context, artist database.Artist) Artist {
 	return Artist{
-		Id: artist.Id,
-		Name: artist.Name,
-		Picture: ConvertArtistPicture(c, artist.Id, artist.Picture),
-		Tags:    utils.SplitString(artist.Tags.String),
-		Created: artist.Created,
-		Updated: artist.Updated,
+		Id:        artist.Id,
+		Name:      artist.Name,
+		OtherName: ConvertSqlNullString(artist.OtherName),
+		Picture:   ConvertArtistPicture(c, artist.Id, artist.Picture),
+		Tags:      utils.SplitString(artist.Tags.String),
+		Created:   artist.Created,
+		Updated:   artist.Updated,
 	}
 }
 
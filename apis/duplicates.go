@@ -0,0 +1,270 @@
+package apis
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/pyrin"
+)
+
+type DuplicateAlbumMatch string
+
+const (
+	// DuplicateAlbumMatchHash is albums with the same audio in every track
+	DuplicateAlbumMatchHash DuplicateAlbumMatch = "hash"
+	// DuplicateAlbumMatchMetadata is albums with the same name, artist and
+	// number of tracks
+	DuplicateAlbumMatchMetadata DuplicateAlbumMatch = "metadata"
+)
+
+type DuplicateTrack struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	AlbumId    string `json:"albumId"`
+	AlbumName  string `json:"albumName"`
+	ArtistName string `json:"artistName"`
+	Path       string `json:"path"`
+}
+
+type DuplicateTrackGroup struct {
+	Hash   string           `json:"hash"`
+	Tracks []DuplicateTrack `json:"tracks"`
+}
+
+type DuplicateAlbum struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	ArtistName  string   `json:"artistName"`
+	LibraryRoot *string  `json:"libraryRoot"`
+	NumTracks   int      `json:"numTracks"`
+	Paths       []string `json:"paths"`
+}
+
+type DuplicateAlbumGroup struct {
+	Match  DuplicateAlbumMatch `json:"match"`
+	Albums []DuplicateAlbum    `json:"albums"`
+}
+
+type GetDuplicates struct {
+	Tracks []DuplicateTrackGroup `json:"tracks"`
+	Albums []DuplicateAlbumGroup `json:"albums"`
+
+	// NumUnhashedTracks is the tracks without a content hash, the tracks
+	// synced before the hashes was added are hashed on the next sync
+	NumUnhashedTracks int `json:"numUnhashedTracks"`
+}
+
+func convertDuplicateAlbum(album *database.Album, tracks []database.Track) DuplicateAlbum {
+	dirs := map[string]struct{}{}
+	for _, track := range tracks {
+		dirs[path.Dir(track.Filename)] = struct{}{}
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+
+	sort.Strings(paths)
+
+	return DuplicateAlbum{
+		Id:          album.Id,
+		Name:        album.Name,
+		ArtistName:  album.ArtistName,
+		LibraryRoot: ConvertSqlNullString(album.LibraryRoot),
+		NumTracks:   len(tracks),
+		Paths:       paths,
+	}
+}
+
+// albumHashSignature returns the sorted content hashes of the tracks,
+// empty if any of the tracks is missing a hash
+func albumHashSignature(tracks []database.Track) string {
+	if len(tracks) == 0 {
+		return ""
+	}
+
+	hashes := make([]string, len(tracks))
+	for i, track := range tracks {
+		if !track.ContentHash.Valid {
+			return ""
+		}
+
+		hashes[i] = track.ContentHash.String
+	}
+
+	sort.Strings(hashes)
+
+	return strings.Join(hashes, ",")
+}
+
+// albumMetadataKey returns the key used to match albums by the metadata,
+// the names is normalized so differences in case and punctuation is ignored
+func albumMetadataKey(album *database.Album, numTracks int) string {
+	return strings.Join([]string{
+		utils.Slug(album.Name),
+		utils.Slug(album.ArtistName),
+		strconv.Itoa(numTracks),
+	}, "\x00")
+}
+
+// findDuplicates finds the tracks with the same content hash and the
+// albums that is likely duplicates, the albums is matched by the hashes
+// first and then by the metadata
+func findDuplicates(ctx context.Context, db *database.Database) (GetDuplicates, error) {
+	albums, err := db.GetAllAlbums(ctx, "", "")
+	if err != nil {
+		return GetDuplicates{}, err
+	}
+
+	tracks, err := db.GetAllTracks(ctx, "", "")
+	if err != nil {
+		return GetDuplicates{}, err
+	}
+
+	res := GetDuplicates{
+		Tracks: []DuplicateTrackGroup{},
+		Albums: []DuplicateAlbumGroup{},
+	}
+
+	albumTracks := map[string][]database.Track{}
+	trackGroups := map[string][]DuplicateTrack{}
+
+	for _, track := range tracks {
+		albumTracks[track.AlbumId] = append(albumTracks[track.AlbumId], track)
+
+		if !track.ContentHash.Valid {
+			res.NumUnhashedTracks++
+			continue
+		}
+
+		trackGroups[track.ContentHash.String] = append(trackGroups[track.ContentHash.String], DuplicateTrack{
+			Id:         track.Id,
+			Name:       track.Name,
+			AlbumId:    track.AlbumId,
+			AlbumName:  track.AlbumName,
+			ArtistName: track.ArtistName,
+			Path:       track.Filename,
+		})
+	}
+
+	for hash, group := range trackGroups {
+		if len(group) < 2 {
+			continue
+		}
+
+		res.Tracks = append(res.Tracks, DuplicateTrackGroup{
+			Hash:   hash,
+			Tracks: group,
+		})
+	}
+
+	sort.Slice(res.Tracks, func(i, j int) bool {
+		a, b := res.Tracks[i].Tracks[0], res.Tracks[j].Tracks[0]
+		if a.AlbumName != b.AlbumName {
+			return a.AlbumName < b.AlbumName
+		}
+
+		return a.Name < b.Name
+	})
+
+	hashGroups := map[string][]int{}
+	metadataGroups := map[string][]int{}
+
+	for i := range albums {
+		album := &albums[i]
+		tracks := albumTracks[album.Id]
+
+		if len(tracks) == 0 {
+			continue
+		}
+
+		if signature := albumHashSignature(tracks); signature != "" {
+			hashGroups[signature] = append(hashGroups[signature], i)
+		}
+
+		key := albumMetadataKey(album, len(tracks))
+		metadataGroups[key] = append(metadataGroups[key], i)
+	}
+
+	// NOTE: A metadata group with the same albums as a hash group is
+	// already reported
+	reported := map[string]struct{}{}
+
+	groupKey := func(indices []int) string {
+		ids := make([]string, len(indices))
+		for i, index := range indices {
+			ids[i] = albums[index].Id
+		}
+
+		sort.Strings(ids)
+
+		return strings.Join(ids, ",")
+	}
+
+	addGroups := func(groups map[string][]int, match DuplicateAlbumMatch) {
+		var added []DuplicateAlbumGroup
+
+		for _, indices := range groups {
+			if len(indices) < 2 {
+				continue
+			}
+
+			key := groupKey(indices)
+			if _, exists := reported[key]; exists {
+				continue
+			}
+
+			reported[key] = struct{}{}
+
+			group := DuplicateAlbumGroup{
+				Match:  match,
+				Albums: make([]DuplicateAlbum, len(indices)),
+			}
+
+			for i, index := range indices {
+				album := &albums[index]
+				group.Albums[i] = convertDuplicateAlbum(album, albumTracks[album.Id])
+			}
+
+			added = append(added, group)
+		}
+
+		sort.Slice(added, func(i, j int) bool {
+			return added[i].Albums[0].Name < added[j].Albums[0].Name
+		})
+
+		res.Albums = append(res.Albums, added...)
+	}
+
+	addGroups(hashGroups, DuplicateAlbumMatchHash)
+	addGroups(metadataGroups, DuplicateAlbumMatchMetadata)
+
+	return res, nil
+}
+
+func InstallDuplicateHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "GetDuplicates",
+			Method:       http.MethodGet,
+			Path:         "/system/library/duplicates",
+			ResponseType: GetDuplicates{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				return findDuplicates(c.Request().Context(), app.DB())
+			},
+		},
+	)
+}
@@ -14,6 +14,10 @@ const (
 	ErrTypeTaglistNotFound  pyrin.ErrorType = "TAGLIST_NOT_FOUND"
 	ErrTypeApiTokenNotFound pyrin.ErrorType = "API_TOKEN_NOT_FOUND"
 	ErrTypeQueueNotFound    pyrin.ErrorType = "QUEUE_NOT_FOUND"
+	ErrTypeSyncRunNotFound  pyrin.ErrorType = "SYNC_RUN_NOT_FOUND"
+	ErrTypeLyricsNotFound   pyrin.ErrorType = "LYRICS_NOT_FOUND"
+
+	ErrTypeLibraryRootNotFound pyrin.ErrorType = "LIBRARY_ROOT_NOT_FOUND"
 
 	ErrTypeInvalidFilter      pyrin.ErrorType = "INVALID_FILTER"
 	ErrTypeInvalidSort        pyrin.ErrorType = "INVALID_SORT"
@@ -136,3 +140,27 @@ func PlaylistAlreadyHasTrack() *pyrin.Error {
 		Message: "Playlist already has track",
 	}
 }
+
+func SyncRunNotFound() *pyrin.Error {
+	return &pyrin.Error{
+		Code:    http.StatusNotFound,
+		Type:    ErrTypeSyncRunNotFound,
+		Message: "Sync run not found",
+	}
+}
+
+func LibraryRootNotFound() *pyrin.Error {
+	return &pyrin.Error{
+		Code:    http.StatusNotFound,
+		Type:    ErrTypeLibraryRootNotFound,
+		Message: "Library root not found",
+	}
+}
+
+func LyricsNotFound() *pyrin.Error {
+	return &pyrin.Error{
+		Code:    http.StatusNotFound,
+		Type:    ErrTypeLyricsNotFound,
+		Message: "Lyrics not found",
+	}
+}
@@ -14,6 +14,12 @@ func InstallHandlers(app core.App, g pyrin.Group) {
 	InstallAuthHandlers(app, g)
 	InstallPlaylistHandlers(app, g)
 	InstallSystemHandlers(app, g)
+	InstallSchedulerHandlers(app, g)
+	InstallSyncHistoryHandlers(app, g)
+	InstallTrashHandlers(app, g)
+	InstallOrphanHandlers(app, g)
+	InstallLoudnessHandlers(app, g)
+	InstallDuplicateHandlers(app, g)
 	InstallTaglistHandlers(app, g)
 	InstallUserHandlers(app, g)
 	InstallMediaHandlers(app, g)
@@ -117,6 +117,23 @@ func ConvertSqlNullInt64(value sql.NullInt64) *int64 {
 	return nil
 }
 
+func ConvertSqlNullFloat64(value sql.NullFloat64) *float64 {
+	if value.Valid {
+		return &value.Float64
+	}
+
+	return nil
+}
+
+// optionalString returns nil for empty strings
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
 const (
 	UNKNOWN_ARTIST_ID   = "unknown"
 	UNKNOWN_ARTIST_NAME = "UNKNOWN"
@@ -143,6 +160,46 @@ func EnsureUnknownArtistExists(ctx context.Context, db *database.Database, workD
 	return nil
 }
 
+const (
+	VARIOUS_ARTISTS_ID   = "various-artists"
+	VARIOUS_ARTISTS_NAME = "Various Artists"
+)
+
+// isVariousArtists reports if the name is "Various Artists" or one of the
+// common short forms like "VA"
+func isVariousArtists(name string) bool {
+	switch utils.Slug(name) {
+	case utils.Slug(VARIOUS_ARTISTS_NAME), "various", "va":
+		return true
+	}
+
+	return false
+}
+
+// EnsureVariousArtistsExists creates the artist used as the primary artist
+// of compilations, an existing artist with the same name is used if the
+// library already has one
+func EnsureVariousArtistsExists(ctx context.Context, db *database.Database) error {
+	_, err := db.GetArtistBySlug(ctx, utils.Slug(VARIOUS_ARTISTS_NAME))
+	if err != nil {
+		if errors.Is(err, database.ErrItemNotFound) {
+			slog.Info("Creating 'various artists' artist")
+			_, err := db.CreateArtist(ctx, database.CreateArtistParams{
+				Id:   VARIOUS_ARTISTS_ID,
+				Name: VARIOUS_ARTISTS_NAME,
+				Slug: utils.Slug(VARIOUS_ARTISTS_NAME),
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
 const (
 	DefaultArtistPictureName = "default/default_artist.png"
 	DefaultAlbumCoverArtName = "default/default_album.png"
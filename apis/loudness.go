@@ -0,0 +1,334 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+	"github.com/nanoteck137/pyrin"
+)
+
+// ReplayGain is the result of the loudness analysis, the gain is in dB and
+// the peak is linear (1.0 is full scale)
+type ReplayGain struct {
+	TrackGain float64  `json:"trackGain"`
+	TrackPeak float64  `json:"trackPeak"`
+	AlbumGain *float64 `json:"albumGain"`
+	AlbumPeak *float64 `json:"albumPeak"`
+}
+
+// ConvertReplayGain returns the ReplayGain of the track, nil if the track
+// hasn't been analysed
+func ConvertReplayGain(track *database.Track) *ReplayGain {
+	if !track.TrackGain.Valid {
+		return nil
+	}
+
+	return &ReplayGain{
+		TrackGain: track.TrackGain.Float64,
+		TrackPeak: track.TrackPeak.Float64,
+		AlbumGain: ConvertSqlNullFloat64(track.AlbumGain),
+		AlbumPeak: ConvertSqlNullFloat64(track.AlbumPeak),
+	}
+}
+
+// normalizeGain returns the gain in dB used for a normalized transcode, the
+// album gain falls back to the track gain and the gain is lowered if the
+// peak would clip. Returns false if the track hasn't been analysed.
+func normalizeGain(track *database.Track, mode types.NormalizeMode) (float64, bool) {
+	gain := track.TrackGain
+	peak := track.TrackPeak
+
+	if mode == types.NormalizeModeAlbum && track.AlbumGain.Valid {
+		gain = track.AlbumGain
+		peak = track.AlbumPeak
+	}
+
+	if !gain.Valid {
+		return 0, false
+	}
+
+	res := gain.Float64
+	if peak.Valid && peak.Float64 > 0 {
+		res = min(res, -utils.LinearToDecibel(peak.Float64))
+	}
+
+	return res, true
+}
+
+// analyzeTrackLoudness runs the ebur128 filter over the track, for tracks
+// with a range only the range is analysed
+func analyzeTrackLoudness(track *database.Track) (utils.Loudness, error) {
+	// NOTE: The per frame log is moved to the verbose level so only the
+	// summary is printed
+	args := ffmpegArgs(track, "-", "-hide_banner", "-nostats", "-vn", "-af", "ebur128=peak=true:framelog=verbose", "-f", "null")
+
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return utils.Loudness{}, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return utils.ParseEbur128Summary(stderr.String())
+}
+
+type LoudnessStatus struct {
+	IsRunning bool `json:"isRunning"`
+
+	// NOTE: The numbers is from the current or the last run
+	NumAnalyzed int `json:"numAnalyzed"`
+	NumFailed   int `json:"numFailed"`
+}
+
+// LoudnessAnalyzer analyses the tracks without ReplayGain values in the
+// background, the album values is updated after the tracks of the album
+// has been analysed
+type LoudnessAnalyzer struct {
+	mutex sync.RWMutex
+
+	isRunning atomic.Bool
+
+	numAnalyzed int
+	numFailed   int
+}
+
+var loudnessAnalyzer = LoudnessAnalyzer{}
+
+func (a *LoudnessAnalyzer) GetStatus() LoudnessStatus {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return LoudnessStatus{
+		IsRunning:   a.isRunning.Load(),
+		NumAnalyzed: a.numAnalyzed,
+		NumFailed:   a.numFailed,
+	}
+}
+
+func (a *LoudnessAnalyzer) addResult(failed bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if failed {
+		a.numFailed++
+	} else {
+		a.numAnalyzed++
+	}
+}
+
+// updateTrack updates the track while holding the library write lock, so
+// the analysis doesn't write at the same time as a sync
+func (a *LoudnessAnalyzer) updateTrack(ctx context.Context, db *database.Database, id string, changes database.TrackChanges) error {
+	libraryWriteMutex.Lock()
+	defer libraryWriteMutex.Unlock()
+
+	return db.UpdateTrack(ctx, id, changes)
+}
+
+func (a *LoudnessAnalyzer) analyzeAlbum(ctx context.Context, db *database.Database, albumId string) error {
+	tracks, err := db.GetTracksByAlbum(ctx, albumId)
+	if err != nil {
+		return err
+	}
+
+	var loudness []utils.Loudness
+	var durations []float64
+
+	for i := range tracks {
+		track := &tracks[i]
+
+		if !track.LoudnessAnalyzed.Valid {
+			analyzed := types.Change[sql.NullInt64]{
+				Value: sql.NullInt64{
+					Int64: time.Now().UnixMilli(),
+					Valid: true,
+				},
+				Changed: true,
+			}
+
+			res, err := analyzeTrackLoudness(track)
+			if err != nil {
+				if errors.Is(err, exec.ErrNotFound) {
+					return err
+				}
+
+				// NOTE: Tracks that can't be analysed (silent or very
+				// short tracks) are marked as analysed without a gain so
+				// they're not queued again, the track is analysed again
+				// if the audio changes
+				slog.Error("Failed to analyze track loudness", "id", track.Id, "file", track.Filename, "err", err)
+
+				err = a.updateTrack(ctx, db, track.Id, database.TrackChanges{
+					LoudnessAnalyzed: analyzed,
+				})
+				if err != nil {
+					return err
+				}
+
+				a.addResult(true)
+				continue
+			}
+
+			track.TrackGain = sql.NullFloat64{Float64: res.Gain(), Valid: true}
+			track.TrackPeak = sql.NullFloat64{Float64: res.Peak(), Valid: true}
+
+			err = a.updateTrack(ctx, db, track.Id, database.TrackChanges{
+				TrackGain: types.Change[sql.NullFloat64]{
+					Value:   track.TrackGain,
+					Changed: true,
+				},
+				TrackPeak: types.Change[sql.NullFloat64]{
+					Value:   track.TrackPeak,
+					Changed: true,
+				},
+				LoudnessAnalyzed: analyzed,
+			})
+			if err != nil {
+				return err
+			}
+
+			a.addResult(false)
+		}
+
+		if !track.TrackGain.Valid {
+			continue
+		}
+
+		loudness = append(loudness, utils.Loudness{
+			Integrated: utils.ReplayGainReference - track.TrackGain.Float64,
+			TruePeak:   utils.LinearToDecibel(track.TrackPeak.Float64),
+		})
+		durations = append(durations, float64(track.Duration))
+	}
+
+	if len(loudness) == 0 {
+		return nil
+	}
+
+	album := utils.AlbumLoudness(loudness, durations)
+
+	albumGain := sql.NullFloat64{Float64: album.Gain(), Valid: true}
+	albumPeak := sql.NullFloat64{Float64: album.Peak(), Valid: true}
+
+	for _, track := range tracks {
+		if !track.TrackGain.Valid {
+			continue
+		}
+
+		err := a.updateTrack(ctx, db, track.Id, database.TrackChanges{
+			AlbumGain: types.Change[sql.NullFloat64]{
+				Value:   albumGain,
+				Changed: albumGain != track.AlbumGain,
+			},
+			AlbumPeak: types.Change[sql.NullFloat64]{
+				Value:   albumPeak,
+				Changed: albumPeak != track.AlbumPeak,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run analyses every album with tracks that needs an analysis
+func (a *LoudnessAnalyzer) Run(app core.App) error {
+	if !a.isRunning.CompareAndSwap(false, true) {
+		return errors.New("loudness analysis is already running")
+	}
+	defer a.isRunning.Store(false)
+
+	a.mutex.Lock()
+	a.numAnalyzed = 0
+	a.numFailed = 0
+	a.mutex.Unlock()
+
+	ctx := context.TODO()
+
+	albumIds, err := app.DB().GetAlbumIdsWithUnanalyzedTracks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, albumId := range albumIds {
+		err := a.analyzeAlbum(ctx, app.DB(), albumId)
+		if err != nil {
+			return fmt.Errorf("failed to analyze album (%s): %w", albumId, err)
+		}
+	}
+
+	return nil
+}
+
+// startLoudnessAnalysis runs the analysis in the background
+func startLoudnessAnalysis(app core.App) {
+	go func() {
+		slog.Info("Started loudness analysis")
+
+		err := loudnessAnalyzer.Run(app)
+		if err != nil {
+			slog.Error("Failed to run loudness analysis", "err", err)
+			return
+		}
+
+		status := loudnessAnalyzer.GetStatus()
+		slog.Info("Loudness analysis done", "analyzed", status.NumAnalyzed, "failed", status.NumFailed)
+	}()
+}
+
+func InstallLoudnessHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "GetLoudnessStatus",
+			Method:       http.MethodGet,
+			Path:         "/system/library/loudness",
+			ResponseType: LoudnessStatus{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				return loudnessAnalyzer.GetStatus(), nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:   "AnalyzeLoudness",
+			Method: http.MethodPost,
+			Path:   "/system/library/loudness",
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				if loudnessAnalyzer.isRunning.Load() {
+					return nil, errors.New("loudness analysis is already running")
+				}
+
+				startLoudnessAnalysis(app)
+
+				return nil, nil
+			},
+		},
+	)
+}
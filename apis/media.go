@@ -28,6 +28,8 @@ type MediaItem struct {
 
 	MediaType types.MediaType `json:"mediaType"`
 	MediaUrl  string          `json:"mediaUrl"`
+
+	ReplayGain *ReplayGain `json:"replayGain"`
 }
 
 type GetMedia struct {
@@ -37,6 +39,10 @@ type GetMedia struct {
 type GetMediaCommonBody struct {
 	MediaType types.MediaType `json:"mediaType,omitempty"`
 
+	// Normalize makes the media url return loudness normalized audio for
+	// clients that can't apply the gain themselves
+	Normalize types.NormalizeMode `json:"normalize,omitempty"`
+
 	Shuffle bool   `json:"shuffle,omitempty"`
 	Sort    string `json:"sort,omitempty"`
 
@@ -73,8 +79,8 @@ type GetMediaFromIdsBody struct {
 	KeepOrder bool     `json:"keepOrder,omitempty"`
 }
 
-func packMediaResult(c pyrin.Context, tracks []database.Track, mediaType types.MediaType, shuffle bool) (GetMedia, error) {
-	if shuffle {
+func packMediaResult(c pyrin.Context, tracks []database.Track, body GetMediaCommonBody) (GetMedia, error) {
+	if body.Shuffle {
 		rand.Shuffle(len(tracks), func(i, j int) {
 			tracks[i], tracks[j] = tracks[j], tracks[i]
 		})
@@ -99,7 +105,7 @@ func packMediaResult(c pyrin.Context, tracks []database.Track, mediaType types.M
 			}
 		}
 
-		mt := mediaType
+		mt := body.MediaType
 		if !mt.IsValid() {
 			mt = track.MediaType
 		}
@@ -110,6 +116,9 @@ func packMediaResult(c pyrin.Context, tracks []database.Track, mediaType types.M
 		}
 
 		mediaUrl := ConvertURL(c, fmt.Sprintf("/files/tracks/%s/track%s", track.Id, ext))
+		if body.Normalize.IsValid() {
+			mediaUrl += "?normalize=" + string(body.Normalize)
+		}
 
 		res.Items[i] = MediaItem{
 			Track: MediaResource{
@@ -121,9 +130,10 @@ func packMediaResult(c pyrin.Context, tracks []database.Track, mediaType types.M
 				Id:   track.AlbumId,
 				Name: track.AlbumName,
 			},
-			CoverArt:  ConvertAlbumCoverURL(c, track.AlbumId, track.AlbumCoverArt),
-			MediaType: mediaType,
-			MediaUrl:  mediaUrl,
+			CoverArt:   ConvertAlbumCoverURL(c, track.AlbumId, track.AlbumCoverArt),
+			MediaType:  body.MediaType,
+			MediaUrl:   mediaUrl,
+			ReplayGain: ConvertReplayGain(&track),
 		}
 	}
 
@@ -173,7 +183,7 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 
@@ -217,7 +227,7 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 
@@ -241,7 +251,7 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 
@@ -277,7 +287,7 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 
@@ -307,18 +317,20 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				sort := body.Sort
-				if sort == "" {
-					sort = "sort=number,name"
+				// NOTE: The default is the album order, the tracks without
+				// a disc is sorted as disc 1
+				var tracks []database.Track
+				if body.Sort == "" {
+					tracks, err = app.DB().GetTracksByAlbum(ctx, album.Id)
+				} else {
+					subquery := database.AlbumTrackSubquery(album.Id)
+					tracks, err = app.DB().GetTracksIn(ctx, subquery, body.Sort)
 				}
-
-				subquery := database.AlbumTrackSubquery(album.Id)
-				tracks, err := app.DB().GetTracksIn(ctx, subquery, sort)
 				if err != nil {
 					return nil, err
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 
@@ -359,7 +371,7 @@ func InstallMediaHandlers(app core.App, group pyrin.Group) {
 					}
 				}
 
-				return packMediaResult(c, tracks, body.MediaType, body.Shuffle)
+				return packMediaResult(c, tracks, body.GetMediaCommonBody)
 			},
 		},
 	)
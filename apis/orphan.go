@@ -0,0 +1,189 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/library"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/pyrin"
+)
+
+type OrphanArtist struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CollectOrphans struct {
+	Artists []OrphanArtist `json:"artists"`
+	Tags    []string       `json:"tags"`
+}
+
+type CollectOrphansBody struct {
+	// DryRun only reports the orphans, nothing is deleted
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// findArtistFileSlugs returns the slugs of the artists with an artist.toml
+// inside the library roots, the artists is kept even if they are unused
+// because the next sync would create them again
+func findArtistFileSlugs(app core.App) (map[string]struct{}, error) {
+	res := map[string]struct{}{}
+
+	for _, root := range app.Config().LibraryRoots() {
+		search, err := library.FindArtists(root.Path, root.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, artist := range search.Artists {
+			res[artist.Slug] = struct{}{}
+		}
+	}
+
+	return res, nil
+}
+
+// collectOrphans deletes the artists and tags that isn't used by anything,
+// the unknown artist and the artists with an artist.toml is never deleted.
+// Returns the orphans that was found.
+func collectOrphans(ctx context.Context, app core.App, dryRun bool) (CollectOrphans, error) {
+	keep, err := findArtistFileSlugs(app)
+	if err != nil {
+		return CollectOrphans{}, err
+	}
+
+	tx, err := app.DB().Begin()
+	if err != nil {
+		return CollectOrphans{}, err
+	}
+	defer tx.Rollback()
+
+	res := CollectOrphans{
+		Artists: []OrphanArtist{},
+		Tags:    []string{},
+	}
+
+	artists, err := tx.GetOrphanedArtists(ctx)
+	if err != nil {
+		return CollectOrphans{}, err
+	}
+
+	for _, artist := range artists {
+		if artist.Id == UNKNOWN_ARTIST_ID || artist.Slug == utils.Slug(VARIOUS_ARTISTS_NAME) {
+			continue
+		}
+
+		if _, exists := keep[artist.Slug]; exists {
+			continue
+		}
+
+		res.Artists = append(res.Artists, OrphanArtist{
+			Id:   artist.Id,
+			Name: artist.Name,
+		})
+
+		if dryRun {
+			continue
+		}
+
+		err := tx.DeleteArtistFromSearch(ctx, artist)
+		if err != nil {
+			return CollectOrphans{}, err
+		}
+
+		err = tx.DeleteArtist(ctx, artist.Id)
+		if err != nil {
+			return CollectOrphans{}, err
+		}
+	}
+
+	// NOTE: The tags is collected after the artists because the tags of
+	// the deleted artists can become unused
+	tags, err := tx.GetOrphanedTags(ctx)
+	if err != nil {
+		return CollectOrphans{}, err
+	}
+
+	for _, tag := range tags {
+		res.Tags = append(res.Tags, tag.Slug)
+
+		if dryRun {
+			continue
+		}
+
+		err := tx.DeleteTag(ctx, tag.Slug)
+		if err != nil {
+			return CollectOrphans{}, err
+		}
+	}
+
+	if dryRun {
+		return res, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return CollectOrphans{}, err
+	}
+
+	for _, artist := range res.Artists {
+		slog.Info("Deleted orphaned artist", "id", artist.Id, "name", artist.Name)
+
+		err := os.RemoveAll(app.WorkDir().Artist(artist.Id))
+		if err != nil {
+			slog.Error("Failed to remove artist directory", "id", artist.Id, "err", err)
+		}
+	}
+
+	for _, tag := range res.Tags {
+		slog.Info("Deleted orphaned tag", "slug", tag)
+	}
+
+	return res, nil
+}
+
+func InstallOrphanHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "CollectOrphans",
+			Method:       http.MethodPost,
+			Path:         "/system/library/orphans",
+			ResponseType: CollectOrphans{},
+			BodyType:     CollectOrphansBody{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				body, err := pyrin.Body[CollectOrphansBody](c)
+				if err != nil {
+					return nil, err
+				}
+
+				// NOTE: A sync can't start while the orphans is collected,
+				// it could link an album to an artist that is deleted
+				var res CollectOrphans
+				err = syncHandler.runExclusive(func() error {
+					var err error
+					res, err = collectOrphans(c.Request().Context(), app, body.DryRun)
+					return err
+				})
+				if err != nil {
+					if errors.Is(err, ErrSyncInProgress) {
+						return nil, errors.New("library is syncing")
+					}
+
+					return nil, err
+				}
+
+				return res, nil
+			},
+		},
+	)
+}
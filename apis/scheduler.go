@@ -0,0 +1,255 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nanoteck137/dwebble/config"
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/pyrin"
+)
+
+const maxScheduledRuns = 20
+
+type ScheduledRunStatus string
+
+const (
+	ScheduledRunStatusSuccess ScheduledRunStatus = "success"
+	ScheduledRunStatusFailed  ScheduledRunStatus = "failed"
+	ScheduledRunStatusSkipped ScheduledRunStatus = "skipped"
+)
+
+type ScheduledRun struct {
+	Started  int64              `json:"started"`
+	Finished int64              `json:"finished"`
+	Status   ScheduledRunStatus `json:"status"`
+	Error    *string            `json:"error,omitempty"`
+
+	NumSyncErrors    int `json:"numSyncErrors"`
+	NumMissingAlbums int `json:"numMissingAlbums"`
+	NumMissingTracks int `json:"numMissingTracks"`
+
+	RefilledSearch bool `json:"refilledSearch"`
+	CleanedUp      bool `json:"cleanedUp"`
+}
+
+// SyncScheduler runs the library sync from the root at a fixed interval,
+// the aliases (@hourly, @daily, @weekly) is aligned to the wall clock (UTC)
+type SyncScheduler struct {
+	mutex sync.RWMutex
+
+	enabled  bool
+	schedule string
+	interval time.Duration
+	nextRun  time.Time
+
+	// NOTE: Newest run first
+	runs []ScheduledRun
+}
+
+var syncScheduler = SyncScheduler{}
+
+func StartSyncScheduler(app core.App) error {
+	schedule := app.Config().ScheduleSync
+	if schedule == "" {
+		return nil
+	}
+
+	interval, err := config.ParseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	next := now.Add(interval)
+	if config.IsAlignedSchedule(schedule) {
+		// NOTE: Truncate rounds down from the zero time (UTC), so the
+		// first run is at the next hour, midnight or monday
+		next = now.Truncate(interval).Add(interval)
+	}
+
+	syncScheduler.mutex.Lock()
+	syncScheduler.enabled = true
+	syncScheduler.schedule = schedule
+	syncScheduler.interval = interval
+	syncScheduler.nextRun = next
+	syncScheduler.mutex.Unlock()
+
+	slog.Info("Scheduled library sync", "schedule", schedule, "interval", interval, "nextRun", next)
+
+	go func() {
+		for {
+			time.Sleep(time.Until(next))
+
+			// NOTE: Runs that takes longer than the interval skips the
+			// missed runs instead of running them back to back
+			for !next.After(time.Now()) {
+				next = next.Add(interval)
+			}
+
+			syncScheduler.mutex.Lock()
+			syncScheduler.nextRun = next
+			syncScheduler.mutex.Unlock()
+
+			run := syncScheduler.runOnce(app)
+			syncScheduler.addRun(run)
+		}
+	}()
+
+	return nil
+}
+
+func (s *SyncScheduler) addRun(run ScheduledRun) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.runs = append([]ScheduledRun{run}, s.runs...)
+	if len(s.runs) > maxScheduledRuns {
+		s.runs = s.runs[:maxScheduledRuns]
+	}
+}
+
+func (s *SyncScheduler) runOnce(app core.App) ScheduledRun {
+	run := ScheduledRun{
+		Started: time.Now().UnixMilli(),
+	}
+
+	fail := func(err error) ScheduledRun {
+		msg := err.Error()
+
+		run.Status = ScheduledRunStatusFailed
+		run.Error = &msg
+		run.Finished = time.Now().UnixMilli()
+
+		slog.Error("Scheduled sync failed", "err", err)
+
+		return run
+	}
+
+	slog.Info("Started scheduled library sync")
+
+	err := syncHandler.RunSync(app, "", "", SyncOptions{})
+	if err != nil {
+		if errors.Is(err, ErrSyncInProgress) {
+			run.Status = ScheduledRunStatusSkipped
+			run.Finished = time.Now().UnixMilli()
+
+			slog.Info("Scheduled sync skipped, sync in progress")
+
+			return run
+		}
+
+		return fail(err)
+	}
+
+	report := syncHandler.GetReport()
+	run.NumSyncErrors = len(report.SyncErrors)
+	run.NumMissingAlbums = len(report.MissingAlbums)
+	run.NumMissingTracks = len(report.MissingTracks)
+
+	if app.Config().ScheduleCleanup {
+		err := syncHandler.Cleanup(app)
+		if err != nil {
+			return fail(err)
+		}
+
+		run.CleanedUp = true
+	}
+
+	if app.Config().ScheduleRefillSearch {
+		ctx := context.TODO()
+
+		err := app.DB().RefillSearchTables(ctx)
+		if err != nil {
+			return fail(err)
+		}
+
+		run.RefilledSearch = true
+	}
+
+	run.Status = ScheduledRunStatusSuccess
+	run.Finished = time.Now().UnixMilli()
+
+	slog.Info(
+		"Scheduled library sync done",
+		"syncErrors", run.NumSyncErrors,
+		"missingAlbums", run.NumMissingAlbums,
+		"missingTracks", run.NumMissingTracks,
+		"cleanedUp", run.CleanedUp,
+		"refilledSearch", run.RefilledSearch,
+	)
+
+	return run
+}
+
+type GetSyncSchedule struct {
+	Enabled  bool           `json:"enabled"`
+	Schedule string         `json:"schedule"`
+	NextRun  *int64         `json:"nextRun,omitempty"`
+	Runs     []ScheduledRun `json:"runs"`
+}
+
+func InstallSchedulerHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "GetSyncSchedule",
+			Method:       http.MethodGet,
+			Path:         "/system/schedule",
+			ResponseType: GetSyncSchedule{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				syncScheduler.mutex.RLock()
+				defer syncScheduler.mutex.RUnlock()
+
+				res := GetSyncSchedule{
+					Enabled:  syncScheduler.enabled,
+					Schedule: syncScheduler.schedule,
+					Runs:     make([]ScheduledRun, len(syncScheduler.runs)),
+				}
+
+				copy(res.Runs, syncScheduler.runs)
+
+				if syncScheduler.enabled {
+					nextRun := syncScheduler.nextRun.UnixMilli()
+					res.NextRun = &nextRun
+				}
+
+				return res, nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:   "RunScheduledSync",
+			Method: http.MethodPost,
+			Path:   "/system/schedule/run",
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				// NOTE: Only to report the error to the caller, a sync
+				// started after this check makes the run skipped
+				if syncHandler.isSyncing.Load() {
+					return nil, errors.New("library is syncing")
+				}
+
+				go func() {
+					run := syncScheduler.runOnce(app)
+					syncScheduler.addRun(run)
+				}()
+
+				return nil, nil
+			},
+		},
+	)
+}
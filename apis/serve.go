@@ -3,6 +3,7 @@ package apis
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
@@ -18,6 +19,58 @@ import (
 	"github.com/nanoteck137/pyrin"
 )
 
+// trackCacheName returns the name of the converted track inside the cache,
+// the range and the gain is included in the name so a changed range or a
+// new analysis is converted again
+func trackCacheName(track *database.Track, gain *float64, ext string) string {
+	name := "track"
+
+	if track.RangeStart.Valid || track.RangeEnd.Valid {
+		name += fmt.Sprintf("-%d-%d", track.RangeStart.Int64, track.RangeEnd.Int64)
+	}
+
+	if gain != nil {
+		name += fmt.Sprintf("-gain%.2f", *gain)
+	}
+
+	return name + ext
+}
+
+// gainArgs prepends the ffmpeg arguments to apply the gain to `args`, the
+// gain is nil if the track shouldn't be normalized
+func gainArgs(gain *float64, args ...string) []string {
+	if gain == nil {
+		return args
+	}
+
+	return append([]string{"-af", fmt.Sprintf("volume=%.2fdB", *gain)}, args...)
+}
+
+func formatMillis(ms int64) string {
+	return fmt.Sprintf("%d.%03d", ms/1000, ms%1000)
+}
+
+// ffmpegArgs returns the ffmpeg arguments to convert the track to `dest`,
+// for tracks with a range only the range is converted
+func ffmpegArgs(track *database.Track, dest string, args ...string) []string {
+	var res []string
+
+	if track.RangeStart.Valid {
+		res = append(res, "-ss", formatMillis(track.RangeStart.Int64))
+	}
+
+	res = append(res, "-i", track.Filename)
+
+	if track.RangeEnd.Valid {
+		res = append(res, "-t", formatMillis(track.RangeEnd.Int64-track.RangeStart.Int64))
+	}
+
+	res = append(res, args...)
+	res = append(res, dest)
+
+	return res
+}
+
 func RegisterHandlers(app core.App, router pyrin.Router) {
 	g := router.Group("/api/v1")
 	InstallHandlers(app, g)
@@ -180,9 +233,29 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 					return pyrin.NoContentNotFound()
 				}
 
+				// NOTE: ALAC and AAC uses the same extension, so .m4a
+				// returns the ALAC file for ALAC tracks
+				if mediaType == types.MediaTypeM4a && track.MediaType == types.MediaTypeAlac {
+					mediaType = types.MediaTypeAlac
+				}
+
+				c.Response().Header().Set("Content-Type", mediaType.ContentType())
+
+				// NOTE: Tracks without an analysis is served without
+				// the normalization
+				var gain *float64
+				mode := types.NormalizeMode(c.Request().URL.Query().Get("normalize"))
+				if mode.IsValid() {
+					if g, ok := normalizeGain(&track, mode); ok {
+						gain = &g
+					}
+				}
+
+				hasRange := track.RangeStart.Valid || track.RangeEnd.Valid
+
 				// Return the original file if the filename matches the
 				// one stored inside the track
-				if track.MediaType == mediaType {
+				if track.MediaType == mediaType && !hasRange && gain == nil {
 					d := path.Dir(track.Filename)
 					filename := path.Base(track.Filename)
 
@@ -210,15 +283,46 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 					}
 				}
 
+				// NOTE: Tracks that only uses a range of the file or is
+				// normalized is converted into the cache with the same
+				// format
+				if track.MediaType == mediaType {
+					ext, _ := mediaType.ToExt()
+					name := trackCacheName(&track, gain, ext)
+					p := path.Join(trackCache, name)
+
+					// NOTE: The default encoder for .m4a is AAC
+					args := gainArgs(gain)
+					if mediaType == types.MediaTypeAlac {
+						args = append(args, "-codec:a", "alac", "-vn")
+					}
+
+					_, err := os.Stat(p)
+					if err != nil {
+						if os.IsNotExist(err) {
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, args...)...)
+							err := cmd.Run()
+							if err != nil {
+								return err
+							}
+						} else {
+							return err
+						}
+					}
+
+					f := os.DirFS(trackCache)
+					return pyrin.ServeFile(c, f, name)
+				}
+
 				switch mediaType {
 				case types.MediaTypeMp3:
-					name := "track.mp3"
+					name := trackCacheName(&track, gain, ".mp3")
 					p := path.Join(trackCache, name)
 
 					_, err := os.Stat(p)
 					if err != nil {
 						if os.IsNotExist(err) {
-							cmd := exec.Command("ffmpeg", "-i", track.Filename, "-b:a", "320k", p)
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-b:a", "320k")...)...)
 							err := cmd.Run()
 							if err != nil {
 								return err
@@ -231,13 +335,13 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 					f := os.DirFS(trackCache)
 					return pyrin.ServeFile(c, f, name)
 				case types.MediaTypeOggOpus:
-					name := "track.opus"
+					name := trackCacheName(&track, gain, ".opus")
 					p := path.Join(trackCache, name)
 
 					_, err := os.Stat(p)
 					if err != nil {
 						if os.IsNotExist(err) {
-							cmd := exec.Command("ffmpeg", "-i", track.Filename, "-b:a", "96k", p)
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-b:a", "96k")...)...)
 							err := cmd.Run()
 							if err != nil {
 								return err
@@ -250,13 +354,13 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 					f := os.DirFS(trackCache)
 					return pyrin.ServeFile(c, f, name)
 				case types.MediaTypeOggVorbis:
-					name := "track.ogg"
+					name := trackCacheName(&track, gain, ".ogg")
 					p := path.Join(trackCache, name)
 
 					_, err := os.Stat(p)
 					if err != nil {
 						if os.IsNotExist(err) {
-							cmd := exec.Command("ffmpeg", "-i", track.Filename, "-b:a", "96k", p)
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-b:a", "96k")...)...)
 							err := cmd.Run()
 							if err != nil {
 								return err
@@ -269,13 +373,13 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 					f := os.DirFS(trackCache)
 					return pyrin.ServeFile(c, f, name)
 				case types.MediaTypeAac:
-					name := "track.aac"
+					name := trackCacheName(&track, gain, ".aac")
 					p := path.Join(trackCache, name)
 
 					_, err := os.Stat(p)
 					if err != nil {
 						if os.IsNotExist(err) {
-							cmd := exec.Command("ffmpeg", "-i", track.Filename, "-codec:a", "aac", "-vn", "-b:a", "128k", p)
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-codec:a", "aac", "-vn", "-b:a", "128k")...)...)
 							cmd.Stderr = os.Stderr
 							err := cmd.Run()
 							if err != nil {
@@ -286,6 +390,63 @@ func RegisterHandlers(app core.App, router pyrin.Router) {
 						}
 					}
 
+					f := os.DirFS(trackCache)
+					return pyrin.ServeFile(c, f, name)
+				case types.MediaTypeM4a:
+					name := trackCacheName(&track, gain, ".m4a")
+					p := path.Join(trackCache, name)
+
+					_, err := os.Stat(p)
+					if err != nil {
+						if os.IsNotExist(err) {
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-codec:a", "aac", "-vn", "-b:a", "256k")...)...)
+							err := cmd.Run()
+							if err != nil {
+								return err
+							}
+						} else {
+							return err
+						}
+					}
+
+					f := os.DirFS(trackCache)
+					return pyrin.ServeFile(c, f, name)
+				case types.MediaTypeFlac:
+					name := trackCacheName(&track, gain, ".flac")
+					p := path.Join(trackCache, name)
+
+					_, err := os.Stat(p)
+					if err != nil {
+						if os.IsNotExist(err) {
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-codec:a", "flac", "-vn")...)...)
+							err := cmd.Run()
+							if err != nil {
+								return err
+							}
+						} else {
+							return err
+						}
+					}
+
+					f := os.DirFS(trackCache)
+					return pyrin.ServeFile(c, f, name)
+				case types.MediaTypeWav:
+					name := trackCacheName(&track, gain, ".wav")
+					p := path.Join(trackCache, name)
+
+					_, err := os.Stat(p)
+					if err != nil {
+						if os.IsNotExist(err) {
+							cmd := exec.Command("ffmpeg", ffmpegArgs(&track, p, gainArgs(gain, "-vn")...)...)
+							err := cmd.Run()
+							if err != nil {
+								return err
+							}
+						} else {
+							return err
+						}
+					}
+
 					f := os.DirFS(trackCache)
 					return pyrin.ServeFile(c, f, name)
 				}
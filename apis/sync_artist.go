@@ -0,0 +1,317 @@
+package apis
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/dwebble/library"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+)
+
+var artistPictureSizes = []int{128, 256, 512}
+
+// artistPictureName returns the name of the original picture stored inside
+// the artist work directory
+func artistPictureName(src string) string {
+	return "picture" + strings.ToLower(path.Ext(src))
+}
+
+func artistPictureSizeName(size int) string {
+	return fmt.Sprintf("picture-%d.png", size)
+}
+
+// artistPictureSourceName is the file storing the source of the stored
+// picture
+const artistPictureSourceName = "picture.source"
+
+// artistPictureSource returns the path, size and modified time of the
+// source picture, the stored picture is created again if it changes
+func artistPictureSource(src string) (string, error) {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s\n%d\n%d\n", src, stat.Size(), stat.ModTime().UnixNano()), nil
+}
+
+// isArtistPictureOutdated reports if the stored picture for the artist
+// needs to be created from `src`
+func isArtistPictureOutdated(workDir types.WorkDir, artistId, src string) bool {
+	dir := workDir.Artist(artistId)
+
+	source, err := artistPictureSource(src)
+	if err != nil {
+		return true
+	}
+
+	stored, err := os.ReadFile(path.Join(dir, artistPictureSourceName))
+	if err != nil || string(stored) != source {
+		return true
+	}
+
+	_, err = os.Stat(path.Join(dir, artistPictureName(src)))
+	if err != nil {
+		return true
+	}
+
+	for _, size := range artistPictureSizes {
+		_, err := os.Stat(path.Join(dir, artistPictureSizeName(size)))
+		if err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func copyFile(src, dest string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, srcFile)
+	if err != nil {
+		return err
+	}
+
+	return destFile.Close()
+}
+
+// createArtistPicture copies the picture to the artist work directory and
+// creates the resized versions, returns the name of the stored picture
+func createArtistPicture(workDir types.WorkDir, artistId, src string) (string, error) {
+	name := artistPictureName(src)
+
+	if !isArtistPictureOutdated(workDir, artistId, src) {
+		return name, nil
+	}
+
+	dir := workDir.Artist(artistId)
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	err = copyFile(src, path.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to copy artist picture: %w", err)
+	}
+
+	for _, size := range artistPictureSizes {
+		dest := path.Join(dir, artistPictureSizeName(size))
+
+		err := utils.CreateResizedImage(src, dest, size, size)
+		if err != nil {
+			return "", fmt.Errorf("failed to create artist picture (%d): %w", size, err)
+		}
+	}
+
+	// NOTE: The source is written last so a failed copy is retried on
+	// the next sync
+	source, err := artistPictureSource(src)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(path.Join(dir, artistPictureSourceName), []byte(source), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// removeArtistPicture removes the stored picture files for the artist
+func removeArtistPicture(workDir types.WorkDir, artistId, name string) error {
+	dir := workDir.Artist(artistId)
+
+	names := []string{name, artistPictureSourceName}
+	for _, size := range artistPictureSizes {
+		names = append(names, artistPictureSizeName(size))
+	}
+
+	for _, name := range names {
+		err := os.Remove(path.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createArtistFileChanges creates the changes needed to make the database
+// artist match the artist.toml, `picture` is the name of the stored
+// picture
+func createArtistFileChanges(metadata *library.ArtistMetadata, dbArtist *database.Artist, picture string) database.ArtistChanges {
+	changes := database.ArtistChanges{}
+
+	changes.Name = types.Change[string]{
+		Value:   metadata.Name,
+		Changed: metadata.Name != dbArtist.Name,
+	}
+
+	changes.OtherName = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: metadata.OtherName,
+			Valid:  metadata.OtherName != "",
+		},
+		Changed: metadata.OtherName != dbArtist.OtherName.String,
+	}
+
+	changes.Picture = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: picture,
+			Valid:  picture != "",
+		},
+		Changed: picture != dbArtist.Picture.String,
+	}
+
+	return changes
+}
+
+func (helper *SyncHelper) setArtistTags(ctx context.Context, db *database.Tx, artistId string, tags []string) error {
+	err := db.RemoveAllTagsFromArtist(ctx, artistId)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		slug := utils.Slug(tag)
+
+		err := db.CreateTag(ctx, slug)
+		if err != nil && !errors.Is(err, database.ErrItemAlreadyExists) {
+			return err
+		}
+
+		err = db.AddTagToArtist(ctx, slug, artistId)
+		if err != nil && !errors.Is(err, database.ErrItemAlreadyExists) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureArtistBySlug gets or creates the artist inside a transaction of
+// its own
+func (helper *SyncHelper) ensureArtistBySlug(ctx context.Context, db *database.Database, slug, name string) (string, error) {
+	helper.writeMutex.Lock()
+	defer helper.writeMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	committed := false
+	defer func() {
+		if !committed {
+			helper.resetArtists()
+		}
+	}()
+
+	artistId, err := helper.getOrCreateArtistBySlug(ctx, &tx, slug, name)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	committed = true
+
+	return artistId, nil
+}
+
+// syncArtistTx syncs the artist.toml, the artist is matched by the slug
+// and created if it doesn't exist
+func (helper *SyncHelper) syncArtistTx(ctx context.Context, db *database.Database, workDir types.WorkDir, artist *library.Artist) error {
+	metadata := &artist.Metadata
+
+	artistId, err := helper.ensureArtistBySlug(ctx, db, artist.Slug, metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: The pictures is created before taking the write lock, the
+	// resizing is slow and doesn't use the database
+	picture := ""
+	if metadata.Picture != "" {
+		picture, err = createArtistPicture(workDir, artistId, metadata.Picture)
+		if err != nil {
+			return err
+		}
+	}
+
+	helper.writeMutex.Lock()
+	defer helper.writeMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dbArtist, err := tx.GetArtistById(ctx, artistId)
+	if err != nil {
+		return err
+	}
+
+	changes := createArtistFileChanges(metadata, &dbArtist, picture)
+
+	err = tx.UpdateArtist(ctx, artistId, changes)
+	if err != nil {
+		return err
+	}
+
+	err = helper.setArtistTags(ctx, &tx, artistId, fixArr(metadata.Tags))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if dbArtist.Picture.Valid && changes.Picture.Changed {
+		// NOTE: The resized pictures is shared so only remove them when
+		// the picture was removed
+		if picture == "" {
+			err = removeArtistPicture(workDir, artistId, dbArtist.Picture.String)
+		} else {
+			err = os.Remove(path.Join(workDir.Artist(artistId), dbArtist.Picture.String))
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to remove old artist picture: %w", err)
+		}
+	}
+
+	return nil
+}
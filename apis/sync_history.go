@@ -0,0 +1,145 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/dwebble/types"
+	"github.com/nanoteck137/pyrin"
+)
+
+type SyncRun struct {
+	Id string `json:"id"`
+
+	Root  *string `json:"root,omitempty"`
+	Path  string  `json:"path"`
+	Force bool    `json:"force"`
+
+	Status database.SyncRunStatus `json:"status"`
+	Error  *string                `json:"error,omitempty"`
+
+	Started  int64  `json:"started"`
+	Finished *int64 `json:"finished,omitempty"`
+
+	SyncStats
+
+	NumMissingAlbums int `json:"numMissingAlbums"`
+	NumMissingTracks int `json:"numMissingTracks"`
+	NumErrors        int `json:"numErrors"`
+}
+
+type GetSyncRuns struct {
+	Page types.Page `json:"page"`
+	Runs []SyncRun  `json:"runs"`
+}
+
+type GetSyncRunById struct {
+	SyncRun
+
+	Report *Report `json:"report,omitempty"`
+}
+
+func ConvertDBSyncRun(run database.SyncRun) SyncRun {
+	return SyncRun{
+		Id:       run.Id,
+		Root:     ConvertSqlNullString(run.Root),
+		Path:     run.Path,
+		Force:    run.Force,
+		Status:   run.Status,
+		Error:    ConvertSqlNullString(run.Error),
+		Started:  run.Started,
+		Finished: ConvertSqlNullInt64(run.Finished),
+		SyncStats: SyncStats{
+			NumCreatedAlbums:   run.NumCreatedAlbums,
+			NumUpdatedAlbums:   run.NumUpdatedAlbums,
+			NumUnchangedAlbums: run.NumUnchangedAlbums,
+			NumCreatedTracks:   run.NumCreatedTracks,
+			NumUpdatedTracks:   run.NumUpdatedTracks,
+			NumUnchangedTracks: run.NumUnchangedTracks,
+		},
+		NumMissingAlbums: run.NumMissingAlbums,
+		NumMissingTracks: run.NumMissingTracks,
+		NumErrors:        run.NumErrors,
+	}
+}
+
+func InstallSyncHistoryHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "GetSyncRuns",
+			Method:       http.MethodGet,
+			Path:         "/system/sync/runs",
+			ResponseType: GetSyncRuns{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				q := c.Request().URL.Query()
+				opts := getPageOptions(q)
+
+				runs, pageInfo, err := app.DB().GetSyncRunsPaged(c.Request().Context(), opts)
+				if err != nil {
+					return nil, err
+				}
+
+				res := GetSyncRuns{
+					Page: pageInfo,
+					Runs: make([]SyncRun, len(runs)),
+				}
+
+				for i, run := range runs {
+					res.Runs[i] = ConvertDBSyncRun(run)
+				}
+
+				return res, nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:         "GetSyncRunById",
+			Method:       http.MethodGet,
+			Path:         "/system/sync/runs/:id",
+			ResponseType: GetSyncRunById{},
+			Errors:       []pyrin.ErrorType{ErrTypeSyncRunNotFound},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				id := c.Param("id")
+
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				run, err := app.DB().GetSyncRunById(c.Request().Context(), id)
+				if err != nil {
+					if errors.Is(err, database.ErrItemNotFound) {
+						return nil, SyncRunNotFound()
+					}
+
+					return nil, err
+				}
+
+				res := GetSyncRunById{
+					SyncRun: ConvertDBSyncRun(run),
+				}
+
+				if run.Report.Valid {
+					var report Report
+					err := json.Unmarshal([]byte(run.Report.String), &report)
+					if err != nil {
+						slog.Error("Failed to decode sync run report", "id", run.Id, "err", err)
+					} else {
+						res.Report = &report
+					}
+				}
+
+				return res, nil
+			},
+		},
+	)
+}
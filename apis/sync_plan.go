@@ -0,0 +1,641 @@
+package apis
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/dwebble/library"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+)
+
+type PlanChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type PlanArtist struct {
+	Name    string       `json:"name"`
+	Changes []PlanChange `json:"changes"`
+
+	TagsAdded   []string `json:"tagsAdded,omitempty"`
+	TagsRemoved []string `json:"tagsRemoved,omitempty"`
+}
+
+type PlanTrack struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	File string `json:"file"`
+
+	Changes     []PlanChange `json:"changes,omitempty"`
+	TagsAdded   []string     `json:"tagsAdded,omitempty"`
+	TagsRemoved []string     `json:"tagsRemoved,omitempty"`
+}
+
+type PlanAlbum struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+
+	Changes     []PlanChange `json:"changes,omitempty"`
+	TagsAdded   []string     `json:"tagsAdded,omitempty"`
+	TagsRemoved []string     `json:"tagsRemoved,omitempty"`
+
+	CreateTracks []PlanTrack `json:"createTracks,omitempty"`
+	UpdateTracks []PlanTrack `json:"updateTracks,omitempty"`
+}
+
+// SyncPlan describes what a sync would do to the database without
+// changing anything
+type SyncPlan struct {
+	CreateArtists []string     `json:"createArtists"`
+	UpdateArtists []PlanArtist `json:"updateArtists"`
+	CreateAlbums  []PlanAlbum  `json:"createAlbums"`
+	UpdateAlbums  []PlanAlbum  `json:"updateAlbums"`
+
+	NumUnchangedAlbums int `json:"numUnchangedAlbums"`
+
+	MissingAlbums []MissingAlbum `json:"missingAlbums"`
+	MissingTracks []MissingTrack `json:"missingTracks"`
+
+	Errors []SyncError `json:"errors"`
+}
+
+type syncPlanner struct {
+	helper *SyncHelper
+	db     *database.Database
+
+	// NOTE: Maps the artist slug to the id, artists that would be created
+	// maps to an empty string
+	artists map[string]string
+
+	// NOTE: Maps the artist slug to the index inside UpdateArtists
+	updatedArtists map[string]int
+
+	plan SyncPlan
+}
+
+// resolveArtist returns the id of the artist, artists that doesn't exist
+// is added to the plan and returns an empty id
+func (p *syncPlanner) resolveArtist(ctx context.Context, name string) (string, error) {
+	slug := utils.Slug(name)
+
+	if id, exists := p.artists[slug]; exists {
+		return id, nil
+	}
+
+	id := ""
+
+	artist, err := p.db.GetArtistBySlug(ctx, slug)
+	if err != nil {
+		if !errors.Is(err, database.ErrItemNotFound) {
+			return "", err
+		}
+
+		p.plan.CreateArtists = append(p.plan.CreateArtists, name)
+	} else {
+		id = artist.Id
+	}
+
+	p.artists[slug] = id
+
+	return id, nil
+}
+
+func (p *syncPlanner) resolveArtists(ctx context.Context, names []string) error {
+	for _, name := range names {
+		_, err := p.resolveArtist(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *syncPlanner) planArtistInfo(ctx context.Context, artists []library.MetadataArtist) error {
+	for _, artist := range artists {
+		id, err := p.resolveArtist(ctx, artist.Name)
+		if err != nil {
+			return err
+		}
+
+		slug := utils.Slug(artist.Name)
+		if _, exists := p.updatedArtists[slug]; exists || id == "" {
+			continue
+		}
+
+		dbArtist, err := p.db.GetArtistById(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		changes := createArtistChanges(&artist, &dbArtist)
+		if changes.OtherName.Changed {
+			p.addArtistUpdate(slug, PlanArtist{
+				Name: dbArtist.Name,
+				Changes: []PlanChange{
+					{
+						Field: "otherName",
+						Old:   ConvertSqlNullString(dbArtist.OtherName),
+						New:   ConvertSqlNullString(changes.OtherName.Value),
+					},
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+// addArtistUpdate adds the update to the plan, merging it with the
+// previous update for the same artist
+func (p *syncPlanner) addArtistUpdate(slug string, update PlanArtist) {
+	if i, exists := p.updatedArtists[slug]; exists {
+		prev := &p.plan.UpdateArtists[i]
+		prev.Changes = append(prev.Changes, update.Changes...)
+		prev.TagsAdded = append(prev.TagsAdded, update.TagsAdded...)
+		prev.TagsRemoved = append(prev.TagsRemoved, update.TagsRemoved...)
+		return
+	}
+
+	p.updatedArtists[slug] = len(p.plan.UpdateArtists)
+	p.plan.UpdateArtists = append(p.plan.UpdateArtists, update)
+}
+
+// planArtistFile adds the changes from the artist.toml to the plan
+func (p *syncPlanner) planArtistFile(ctx context.Context, workDir types.WorkDir, artist *library.Artist) error {
+	metadata := &artist.Metadata
+
+	dbArtist, err := p.db.GetArtistBySlug(ctx, artist.Slug)
+	if err != nil {
+		if !errors.Is(err, database.ErrItemNotFound) {
+			return err
+		}
+
+		if _, exists := p.artists[artist.Slug]; !exists {
+			p.artists[artist.Slug] = ""
+			p.plan.CreateArtists = append(p.plan.CreateArtists, metadata.Name)
+		}
+
+		return nil
+	}
+
+	picture := ""
+	if metadata.Picture != "" {
+		picture = artistPictureName(metadata.Picture)
+	}
+
+	changes := createArtistFileChanges(metadata, &dbArtist, picture)
+
+	res := PlanArtist{
+		Name: dbArtist.Name,
+	}
+
+	add := func(field string, changed bool, old, new any) {
+		if changed {
+			res.Changes = append(res.Changes, PlanChange{
+				Field: field,
+				Old:   old,
+				New:   new,
+			})
+		}
+	}
+
+	add("name", changes.Name.Changed, dbArtist.Name, changes.Name.Value)
+	add("otherName", changes.OtherName.Changed, ConvertSqlNullString(dbArtist.OtherName), ConvertSqlNullString(changes.OtherName.Value))
+
+	pictureChanged := changes.Picture.Changed ||
+		(picture != "" && isArtistPictureOutdated(workDir, dbArtist.Id, metadata.Picture))
+	add("picture", pictureChanged, ConvertSqlNullString(dbArtist.Picture), metadata.Picture)
+
+	res.TagsAdded, res.TagsRemoved = diffSlugs(splitTags(dbArtist.Tags), metadata.Tags)
+
+	if len(res.Changes) > 0 || len(res.TagsAdded) > 0 || len(res.TagsRemoved) > 0 {
+		p.addArtistUpdate(artist.Slug, res)
+	}
+
+	return nil
+}
+
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return nil
+	}
+
+	return strings.Split(tags.String, ",")
+}
+
+// diffSlugs returns the slugs inside `new` that's not in `old` and the
+// slugs inside `old` that's not in `new`
+func diffSlugs(old, new []string) ([]string, []string) {
+	oldSet := map[string]struct{}{}
+	for _, value := range old {
+		oldSet[utils.Slug(value)] = struct{}{}
+	}
+
+	newSet := map[string]struct{}{}
+	for _, value := range new {
+		newSet[utils.Slug(value)] = struct{}{}
+	}
+
+	var added []string
+	for _, value := range fixArr(new) {
+		slug := utils.Slug(value)
+		if _, exists := oldSet[slug]; !exists {
+			added = append(added, slug)
+			oldSet[slug] = struct{}{}
+		}
+	}
+
+	var removed []string
+	for _, value := range old {
+		slug := utils.Slug(value)
+		if _, exists := newSet[slug]; !exists {
+			removed = append(removed, slug)
+		}
+	}
+
+	return added, removed
+}
+
+func featuringArtistNames(artists database.FeaturingArtists) []string {
+	res := make([]string, len(artists))
+	for i, artist := range artists {
+		res[i] = artist.Name
+	}
+
+	return res
+}
+
+func featuringArtistsChange(old database.FeaturingArtists, new []string) (PlanChange, bool) {
+	oldNames := featuringArtistNames(old)
+
+	added, removed := diffSlugs(oldNames, new)
+	if len(added) == 0 && len(removed) == 0 {
+		return PlanChange{}, false
+	}
+
+	return PlanChange{
+		Field: "featuringArtists",
+		Old:   oldNames,
+		New:   new,
+	}, true
+}
+
+func (p *syncPlanner) planNewTrack(ctx context.Context, track *library.MetadataTrack) (PlanTrack, error) {
+	err := p.resolveArtists(ctx, track.Artists)
+	if err != nil {
+		return PlanTrack{}, err
+	}
+
+	p.helper.markTrack(track.Id)
+
+	tagsAdded, _ := diffSlugs(nil, track.Tags)
+
+	return PlanTrack{
+		Id:        track.Id,
+		Name:      track.Name,
+		File:      track.File,
+		TagsAdded: tagsAdded,
+	}, nil
+}
+
+func (p *syncPlanner) planTrack(ctx context.Context, track *library.MetadataTrack, dbTrack *database.Track) (PlanTrack, error) {
+	p.helper.markTrack(dbTrack.Id)
+
+	stat, err := os.Stat(track.File)
+	if err != nil {
+		return PlanTrack{}, fmt.Errorf("failed to stat track file (%s): %w", track.File, err)
+	}
+
+	artist, err := p.resolveArtist(ctx, track.Artists[0])
+	if err != nil {
+		return PlanTrack{}, err
+	}
+
+	err = p.resolveArtists(ctx, track.Artists[1:])
+	if err != nil {
+		return PlanTrack{}, err
+	}
+
+	lyrics, err := readTrackLyrics(track)
+	if err != nil {
+		return PlanTrack{}, fmt.Errorf("failed to read track lyrics: %w", err)
+	}
+
+	changes := database.TrackChanges{}
+	setTrackChanges(&changes, track, dbTrack, artist)
+
+	err = setLyricsChanges(ctx, p.db.DB, &changes, lyrics, dbTrack)
+	if err != nil {
+		return PlanTrack{}, err
+	}
+
+	res := PlanTrack{
+		Id:   dbTrack.Id,
+		Name: track.Name,
+		File: track.File,
+	}
+
+	add := func(field string, changed bool, old, new any) {
+		if changed {
+			res.Changes = append(res.Changes, PlanChange{
+				Field: field,
+				Old:   old,
+				New:   new,
+			})
+		}
+	}
+
+	// NOTE: The file is probed again during the sync, so the duration and
+	// media type changes are unknown here
+	modifiedTime := stat.ModTime().UnixMilli()
+	add("modifiedTime", modifiedTime > dbTrack.ModifiedTime, dbTrack.ModifiedTime, modifiedTime)
+
+	add("filename", changes.Filename.Changed, dbTrack.Filename, changes.Filename.Value)
+	add("name", changes.Name.Changed, dbTrack.Name, changes.Name.Value)
+	add("otherName", changes.OtherName.Changed, ConvertSqlNullString(dbTrack.OtherName), ConvertSqlNullString(changes.OtherName.Value))
+	add("artist", changes.ArtistId.Changed, dbTrack.ArtistName, track.Artists[0])
+	add("number", changes.Number.Changed, ConvertSqlNullInt64(dbTrack.Number), ConvertSqlNullInt64(changes.Number.Value))
+	add("year", changes.Year.Changed, ConvertSqlNullInt64(dbTrack.Year), ConvertSqlNullInt64(changes.Year.Value))
+	add("releaseDate", changes.ReleaseDate.Changed, ConvertSqlNullString(dbTrack.ReleaseDate), ConvertSqlNullString(changes.ReleaseDate.Value))
+	add("originalReleaseDate", changes.OriginalReleaseDate.Changed, ConvertSqlNullString(dbTrack.OriginalReleaseDate), ConvertSqlNullString(changes.OriginalReleaseDate.Value))
+	add("disc", changes.Disc.Changed, ConvertSqlNullInt64(dbTrack.Disc), ConvertSqlNullInt64(changes.Disc.Value))
+	add("discSubtitle", changes.DiscSubtitle.Changed, ConvertSqlNullString(dbTrack.DiscSubtitle), ConvertSqlNullString(changes.DiscSubtitle.Value))
+	add("rangeStart", changes.RangeStart.Changed, ConvertSqlNullInt64(dbTrack.RangeStart), ConvertSqlNullInt64(changes.RangeStart.Value))
+	add("rangeEnd", changes.RangeEnd.Changed, ConvertSqlNullInt64(dbTrack.RangeEnd), ConvertSqlNullInt64(changes.RangeEnd.Value))
+	add("lyrics", changes.Lyrics.Changed, dbTrack.HasLyrics, changes.Lyrics.Value.Valid)
+	add("trashed", changes.Trashed.Changed, ConvertSqlNullInt64(dbTrack.Trashed), nil)
+
+	if change, changed := featuringArtistsChange(dbTrack.FeaturingArtists, track.Artists[1:]); changed {
+		res.Changes = append(res.Changes, change)
+	}
+
+	res.TagsAdded, res.TagsRemoved = diffSlugs(splitTags(dbTrack.Tags), track.Tags)
+
+	return res, nil
+}
+
+func (p *syncPlanner) planAlbum(ctx context.Context, album *library.Album, options SyncOptions) error {
+	if !options.Force {
+		unchanged, err := p.helper.isAlbumUnchanged(ctx, p.db, album)
+		if err != nil {
+			return err
+		}
+
+		if unchanged {
+			p.plan.NumUnchangedAlbums++
+			return nil
+		}
+	}
+
+	_, err := album.Load()
+	if err != nil {
+		return err
+	}
+
+	metadata := &album.Metadata
+
+	err = FixMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = p.planArtistInfo(ctx, metadata.Artists)
+	if err != nil {
+		return err
+	}
+
+	res := PlanAlbum{
+		Id:   metadata.Album.Id,
+		Name: metadata.Album.Name,
+		Path: album.Path,
+	}
+
+	dbAlbum, err := p.db.GetAlbumByIdWithTrashed(ctx, metadata.Album.Id)
+	if err != nil {
+		if !errors.Is(err, database.ErrItemNotFound) {
+			return err
+		}
+
+		err := p.resolveArtists(ctx, metadata.Album.Artists)
+		if err != nil {
+			return err
+		}
+
+		res.TagsAdded, _ = diffSlugs(nil, metadata.Album.Tags)
+
+		for i := range metadata.Tracks {
+			track, err := p.planNewTrack(ctx, &metadata.Tracks[i])
+			if err != nil {
+				return fmt.Errorf("failed to plan track[%d]: %w", i, err)
+			}
+
+			res.CreateTracks = append(res.CreateTracks, track)
+		}
+
+		p.plan.CreateAlbums = append(p.plan.CreateAlbums, res)
+
+		return nil
+	}
+
+	p.helper.markAlbum(dbAlbum.Id)
+
+	artist, err := p.resolveArtist(ctx, metadata.Album.Artists[0])
+	if err != nil {
+		return err
+	}
+
+	err = p.resolveArtists(ctx, metadata.Album.Artists[1:])
+	if err != nil {
+		return err
+	}
+
+	changes := createAlbumChanges(metadata, &dbAlbum, artist)
+
+	add := func(field string, changed bool, old, new any) {
+		if changed {
+			res.Changes = append(res.Changes, PlanChange{
+				Field: field,
+				Old:   old,
+				New:   new,
+			})
+		}
+	}
+
+	add("name", changes.Name.Changed, dbAlbum.Name, changes.Name.Value)
+	add("otherName", changes.OtherName.Changed, ConvertSqlNullString(dbAlbum.OtherName), ConvertSqlNullString(changes.OtherName.Value))
+	add("artist", changes.ArtistId.Changed, dbAlbum.ArtistName, metadata.Album.Artists[0])
+	add("coverArt", changes.CoverArt.Changed, ConvertSqlNullString(dbAlbum.CoverArt), ConvertSqlNullString(changes.CoverArt.Value))
+	add("year", changes.Year.Changed, ConvertSqlNullInt64(dbAlbum.Year), ConvertSqlNullInt64(changes.Year.Value))
+	add("releaseDate", changes.ReleaseDate.Changed, ConvertSqlNullString(dbAlbum.ReleaseDate), ConvertSqlNullString(changes.ReleaseDate.Value))
+	add("originalReleaseDate", changes.OriginalReleaseDate.Changed, ConvertSqlNullString(dbAlbum.OriginalReleaseDate), ConvertSqlNullString(changes.OriginalReleaseDate.Value))
+	add("releaseType", changes.ReleaseType.Changed, dbAlbum.ReleaseType, changes.ReleaseType.Value)
+	add("trashed", changes.Trashed.Changed, ConvertSqlNullInt64(dbAlbum.Trashed), nil)
+	add("libraryRoot", dbAlbum.LibraryRoot.String != album.Root, ConvertSqlNullString(dbAlbum.LibraryRoot), album.Root)
+
+	if change, changed := featuringArtistsChange(dbAlbum.FeaturingArtists, metadata.Album.Artists[1:]); changed {
+		res.Changes = append(res.Changes, change)
+	}
+
+	res.TagsAdded, res.TagsRemoved = diffSlugs(splitTags(dbAlbum.Tags), metadata.Album.Tags)
+
+	for i := range metadata.Tracks {
+		track := &metadata.Tracks[i]
+
+		dbTrack, err := p.db.GetTrackByIdWithTrashed(ctx, track.Id)
+		if err != nil {
+			if !errors.Is(err, database.ErrItemNotFound) {
+				return err
+			}
+
+			planTrack, err := p.planNewTrack(ctx, track)
+			if err != nil {
+				return fmt.Errorf("failed to plan track[%d]: %w", i, err)
+			}
+
+			res.CreateTracks = append(res.CreateTracks, planTrack)
+			continue
+		}
+
+		planTrack, err := p.planTrack(ctx, track, &dbTrack)
+		if err != nil {
+			return fmt.Errorf("failed to plan track[%d]: %w", i, err)
+		}
+
+		if len(planTrack.Changes) > 0 || len(planTrack.TagsAdded) > 0 || len(planTrack.TagsRemoved) > 0 {
+			res.UpdateTracks = append(res.UpdateTracks, planTrack)
+		}
+	}
+
+	if len(res.Changes) == 0 &&
+		len(res.TagsAdded) == 0 &&
+		len(res.TagsRemoved) == 0 &&
+		len(res.CreateTracks) == 0 &&
+		len(res.UpdateTracks) == 0 {
+		p.plan.NumUnchangedAlbums++
+		return nil
+	}
+
+	p.plan.UpdateAlbums = append(p.plan.UpdateAlbums, res)
+
+	return nil
+}
+
+// PlanSync runs the same steps as RunSync but only compares the library
+// against the database, nothing is written
+func (s *SyncHandler) PlanSync(app core.App, root, p string, options SyncOptions) (SyncPlan, error) {
+	targets, err := resolveSyncTargets(app, root, p)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	search, err := searchTargets(app, targets)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	ctx := context.TODO()
+
+	planner := syncPlanner{
+		helper:         NewSyncHelper(),
+		db:             app.DB(),
+		artists:        map[string]string{},
+		updatedArtists: map[string]int{},
+		plan: SyncPlan{
+			CreateArtists: []string{},
+			UpdateArtists: []PlanArtist{},
+			CreateAlbums:  []PlanAlbum{},
+			UpdateAlbums:  []PlanAlbum{},
+			MissingAlbums: []MissingAlbum{},
+			MissingTracks: []MissingTrack{},
+		},
+	}
+
+	// NOTE: The unknown artist is created by the sync if it's missing
+	planner.artists[utils.Slug(UNKNOWN_ARTIST_NAME)] = UNKNOWN_ARTIST_ID
+
+	albums, duplicateErrors := planner.helper.skipDuplicates(search.albums)
+
+	errs := createSearchErrors(search.errors)
+	errs = append(errs, duplicateErrors...)
+
+	for _, album := range albums {
+		err := planner.planAlbum(ctx, album, options)
+		if err != nil {
+			errs = append(errs, SyncError{
+				Type:    ReportTypeSync,
+				Message: fmt.Sprintf("%s: %v", album.Path, err),
+			})
+		}
+	}
+
+	for _, artist := range search.artists {
+		err := planner.planArtistFile(ctx, app.WorkDir(), &artist)
+		if err != nil {
+			errs = append(errs, SyncError{
+				Type:    ReportTypeSync,
+				Message: fmt.Sprintf("%s: %v", artist.Path, err),
+			})
+		}
+	}
+
+	missingAlbums, missingTracks, err := planner.helper.findMissing(ctx, app.DB(), targets)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	if missingAlbums != nil {
+		planner.plan.MissingAlbums = missingAlbums
+	}
+
+	if missingTracks != nil {
+		planner.plan.MissingTracks = missingTracks
+	}
+
+	planner.plan.Errors = errs
+
+	return planner.plan, nil
+}
+
+// PlanLibrarySync returns the changes a sync of `p` inside `root` would
+// make
+func PlanLibrarySync(app core.App, root, p string, options SyncOptions) (SyncPlan, error) {
+	return syncHandler.PlanSync(app, root, p, options)
+}
+
+// RunLibrarySync syncs `p` inside `root` and returns the report from the
+// sync, used by the sync command. The background jobs is skipped because
+// the process exits after the sync, instead the loudness analysis runs
+// before returning if it's enabled.
+func RunLibrarySync(app core.App, root, p string, options SyncOptions) (Report, error) {
+	options.NoBackgroundJobs = true
+
+	err := syncHandler.RunSync(app, root, p, options)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if app.Config().AnalyzeLoudness {
+		slog.Info("Started loudness analysis")
+
+		err := loudnessAnalyzer.Run(app)
+		if err != nil {
+			slog.Error("Failed to run loudness analysis", "err", err)
+		} else {
+			status := loudnessAnalyzer.GetStatus()
+			slog.Info("Loudness analysis done", "analyzed", status.NumAnalyzed, "failed", status.NumFailed)
+		}
+	}
+
+	return syncHandler.GetReport(), nil
+}
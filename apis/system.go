@@ -7,14 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/nanoteck137/dwebble"
+	"github.com/nanoteck137/dwebble/config"
 	"github.com/nanoteck137/dwebble/core"
 	"github.com/nanoteck137/dwebble/database"
 	"github.com/nanoteck137/dwebble/library"
@@ -22,7 +27,6 @@ import (
 	"github.com/nanoteck137/dwebble/types"
 	"github.com/nanoteck137/pyrin"
 	"github.com/nanoteck137/pyrin/anvil"
-	"github.com/pelletier/go-toml/v2"
 )
 
 type GetSystemInfo struct {
@@ -48,30 +52,112 @@ func fixArr(arr []string) []string {
 	return res
 }
 
+// compilationArtists makes "Various Artists" the primary artist of the
+// compilation, the other album artists becomes featuring artists
+func compilationArtists(artists []string) []string {
+	res := make([]string, 0, len(artists)+1)
+	res = append(res, VARIOUS_ARTISTS_NAME)
+
+	for _, artist := range artists {
+		if !isVariousArtists(artist) {
+			res = append(res, artist)
+		}
+	}
+
+	return res
+}
+
+// fixReleaseDate normalizes the release date, a missing year is taken from
+// the date or the parent and a missing date is taken from the parent if
+// the years match, otherwise only the year is used as the date
+func fixReleaseDate(date string, year int64, parentDate string, parentYear int64) (string, int64) {
+	if date != "" {
+		var err error
+		date, err = utils.NormalizeReleaseDate(date)
+		if err != nil {
+			date = ""
+		}
+	}
+
+	if year == 0 {
+		year = utils.ReleaseDateYear(date)
+	}
+
+	if year == 0 {
+		year = parentYear
+	}
+
+	if date == "" {
+		switch {
+		case year == 0 || utils.ReleaseDateYear(parentDate) == year:
+			date = parentDate
+		default:
+			date = fmt.Sprintf("%04d", year)
+		}
+	}
+
+	return date, year
+}
+
+// fixOriginalReleaseDate normalizes the original release date, the date
+// from the parent is used if not set
+func fixOriginalReleaseDate(date string, parentDate string) string {
+	if date == "" {
+		return parentDate
+	}
+
+	date, err := utils.NormalizeReleaseDate(date)
+	if err != nil {
+		return parentDate
+	}
+
+	return date
+}
+
 // TODO(patrik): Add testing for this
 func FixMetadata(metadata *library.Metadata) error {
+	general := &metadata.General
 	album := &metadata.Album
 
 	album.Name = anvil.String(album.Name)
+	album.OtherName = anvil.String(album.OtherName)
+
+	general.ReleaseDate, general.Year = fixReleaseDate(general.ReleaseDate, general.Year, "", 0)
+	general.OriginalReleaseDate = fixOriginalReleaseDate(general.OriginalReleaseDate, "")
+
+	album.ReleaseDate, album.Year = fixReleaseDate(album.ReleaseDate, album.Year, general.ReleaseDate, general.Year)
+	album.OriginalReleaseDate = fixOriginalReleaseDate(album.OriginalReleaseDate, general.OriginalReleaseDate)
+
+	album.Artists = fixArr(album.Artists)
+
+	releaseType, ok := types.ParseReleaseType(album.ReleaseType)
+	if !ok {
+		releaseType = types.ReleaseTypeAlbum
 
-	if album.Year == 0 {
-		album.Year = metadata.General.Year
+		// NOTE: Albums credited to "Various Artists" is compilations
+		if len(album.Artists) > 0 && isVariousArtists(album.Artists[0]) {
+			releaseType = types.ReleaseTypeCompilation
+		}
+	}
+
+	album.ReleaseType = string(releaseType)
+
+	if releaseType == types.ReleaseTypeCompilation {
+		album.Artists = compilationArtists(album.Artists)
 	}
 
 	if len(album.Artists) == 0 {
 		album.Artists = []string{UNKNOWN_ARTIST_NAME}
 	}
 
-	album.Artists = fixArr(album.Artists)
-
 	for i := range metadata.Tracks {
 		t := &metadata.Tracks[i]
 
-		if t.Year == 0 {
-			t.Year = metadata.General.Year
-		}
+		t.ReleaseDate, t.Year = fixReleaseDate(t.ReleaseDate, t.Year, album.ReleaseDate, general.Year)
+		t.OriginalReleaseDate = fixOriginalReleaseDate(t.OriginalReleaseDate, album.OriginalReleaseDate)
 
 		t.Name = anvil.String(t.Name)
+		t.OtherName = anvil.String(t.OtherName)
 
 		t.Tags = append(t.Tags, metadata.General.Tags...)
 		t.Tags = append(t.Tags, metadata.General.TrackTags...)
@@ -87,39 +173,137 @@ func FixMetadata(metadata *library.Metadata) error {
 		}
 	}
 
-	// err := validate.ValidateStruct(&metadata.Album,
-	// 	validate.Field(&metadata.Album.Name, validate.Required),
-	// 	validate.Field(&metadata.Album.Artists, validate.Length(1, 0)),
-	// )
-	// if err != nil {
-	// 	return err
-	// }
-	//
-	// for _, track := range metadata.Tracks {
-	// 	err := validate.ValidateStruct(&track,
-	// 		validate.Field(&track.File, validate.Required),
-	// 		validate.Field(&track.Name, validate.Required),
-	// 		validate.Field(&track.Artists, validate.Length(1, 0)),
-	// 	)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
+	for i := range metadata.Artists {
+		a := &metadata.Artists[i]
+
+		a.Name = anvil.String(a.Name)
+		a.OtherName = anvil.String(a.OtherName)
+	}
+
+	// NOTE: The metadata is validated when the album.toml is read, see
+	// library.ValidationError
 
 	return nil
 }
 
+// SyncHelper is shared between the sync workers, the caches are guarded
+// by mutex so it's safe for concurrent use
 type SyncHelper struct {
+	mutex sync.Mutex
+
 	artists map[string]string
 
 	albums map[string]struct{}
 	tracks map[string]struct{}
+
+	// NOTE: SQLite only allows a single writer, so the album transactions
+	// are serialized while the probing runs in parallel, see
+	// libraryWriteMutex
+	writeMutex *sync.Mutex
+
+	stats SyncStats
+}
+
+// SyncStats counts what the sync did, only albums that was committed
+// is counted as created or updated
+type SyncStats struct {
+	NumCreatedAlbums   int `json:"numCreatedAlbums"`
+	NumUpdatedAlbums   int `json:"numUpdatedAlbums"`
+	NumUnchangedAlbums int `json:"numUnchangedAlbums"`
+
+	NumCreatedTracks   int `json:"numCreatedTracks"`
+	NumUpdatedTracks   int `json:"numUpdatedTracks"`
+	NumUnchangedTracks int `json:"numUnchangedTracks"`
+}
+
+func (s *SyncStats) add(other SyncStats) {
+	s.NumCreatedAlbums += other.NumCreatedAlbums
+	s.NumUpdatedAlbums += other.NumUpdatedAlbums
+	s.NumUnchangedAlbums += other.NumUnchangedAlbums
+
+	s.NumCreatedTracks += other.NumCreatedTracks
+	s.NumUpdatedTracks += other.NumUpdatedTracks
+	s.NumUnchangedTracks += other.NumUnchangedTracks
+}
+
+// libraryWriteMutex serializes the library writes from the syncs and the
+// background jobs like the loudness analysis
+var libraryWriteMutex sync.Mutex
+
+func NewSyncHelper() *SyncHelper {
+	return &SyncHelper{
+		artists:    map[string]string{},
+		albums:     map[string]struct{}{},
+		tracks:     map[string]struct{}{},
+		writeMutex: &libraryWriteMutex,
+	}
+}
+
+func (helper *SyncHelper) addStats(stats SyncStats) {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	helper.stats.add(stats)
+}
+
+func (helper *SyncHelper) getStats() SyncStats {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	return helper.stats
+}
+
+func (helper *SyncHelper) markAlbum(id string) {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	helper.albums[id] = struct{}{}
 }
 
-func (helper *SyncHelper) getOrCreateArtist(ctx context.Context, db *database.Database, name string) (string, error) {
-	slug := utils.Slug(name)
+func (helper *SyncHelper) markTrack(id string) {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
 
-	if artist, exists := helper.artists[slug]; exists {
+	helper.tracks[id] = struct{}{}
+}
+
+func (helper *SyncHelper) hasAlbum(id string) bool {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	_, exists := helper.albums[id]
+	return exists
+}
+
+func (helper *SyncHelper) hasTrack(id string) bool {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	_, exists := helper.tracks[id]
+	return exists
+}
+
+// resetArtists clears the artist cache, needed after a rollback because
+// the cache could contain artists that was created inside the transaction
+func (helper *SyncHelper) resetArtists() {
+	helper.mutex.Lock()
+	defer helper.mutex.Unlock()
+
+	helper.artists = map[string]string{}
+}
+
+func (helper *SyncHelper) getOrCreateArtist(ctx context.Context, db *database.Tx, name string) (string, error) {
+	return helper.getOrCreateArtistBySlug(ctx, db, utils.Slug(name), name)
+}
+
+// getOrCreateArtistBySlug returns the id of the artist with `slug`, the
+// artist is created with `name` if it doesn't exist
+func (helper *SyncHelper) getOrCreateArtistBySlug(ctx context.Context, db *database.Tx, slug, name string) (string, error) {
+	helper.mutex.Lock()
+	artist, exists := helper.artists[slug]
+	helper.mutex.Unlock()
+
+	if exists {
 		return artist, nil
 	}
 
@@ -138,11 +322,44 @@ func (helper *SyncHelper) getOrCreateArtist(ctx context.Context, db *database.Da
 		}
 	}
 
+	helper.mutex.Lock()
 	helper.artists[slug] = dbArtist.Id
+	helper.mutex.Unlock()
+
 	return dbArtist.Id, nil
 }
 
-func (helper *SyncHelper) setAlbumFeaturingArtists(ctx context.Context, db *database.Database, albumId string, artists []string) error {
+// setArtistInfo updates the artists with the information from the
+// metadata, only the fields that is set is updated because the artists
+// are shared between albums
+func (helper *SyncHelper) setArtistInfo(ctx context.Context, db *database.Tx, artists []library.MetadataArtist) error {
+	for _, artist := range artists {
+		artistId, err := helper.getOrCreateArtist(ctx, db, artist.Name)
+		if err != nil {
+			return err
+		}
+
+		if artist.OtherName == "" {
+			continue
+		}
+
+		dbArtist, err := db.GetArtistById(ctx, artistId)
+		if err != nil {
+			return err
+		}
+
+		changes := createArtistChanges(&artist, &dbArtist)
+
+		err = db.UpdateArtist(ctx, dbArtist.Id, changes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (helper *SyncHelper) setAlbumFeaturingArtists(ctx context.Context, db *database.Tx, albumId string, artists []string) error {
 	err := db.RemoveAllAlbumFeaturingArtists(ctx, albumId)
 	if err != nil {
 		return err
@@ -163,7 +380,7 @@ func (helper *SyncHelper) setAlbumFeaturingArtists(ctx context.Context, db *data
 	return nil
 }
 
-func (helper *SyncHelper) setAlbumTags(ctx context.Context, db *database.Database, albumId string, tags []string) error {
+func (helper *SyncHelper) setAlbumTags(ctx context.Context, db *database.Tx, albumId string, tags []string) error {
 	err := db.RemoveAllTagsFromAlbum(ctx, albumId)
 	if err != nil {
 		return err
@@ -186,7 +403,7 @@ func (helper *SyncHelper) setAlbumTags(ctx context.Context, db *database.Databas
 	return nil
 }
 
-func (helper *SyncHelper) setTrackFeaturingArtists(ctx context.Context, db *database.Database, trackId string, artists []string) error {
+func (helper *SyncHelper) setTrackFeaturingArtists(ctx context.Context, db *database.Tx, trackId string, artists []string) error {
 	err := db.RemoveAllTrackFeaturingArtists(ctx, trackId)
 	if err != nil {
 		return err
@@ -207,7 +424,7 @@ func (helper *SyncHelper) setTrackFeaturingArtists(ctx context.Context, db *data
 	return nil
 }
 
-func (helper *SyncHelper) setTrackTags(ctx context.Context, db *database.Database, trackId string, tags []string) error {
+func (helper *SyncHelper) setTrackTags(ctx context.Context, db *database.Tx, trackId string, tags []string) error {
 	err := db.RemoveAllTagsFromTrack(ctx, trackId)
 	if err != nil {
 		return err
@@ -230,522 +447,1565 @@ func (helper *SyncHelper) setTrackTags(ctx context.Context, db *database.Databas
 	return nil
 }
 
-// TODO(patrik): Update the errors for album
-func (helper *SyncHelper) syncAlbum(ctx context.Context, metadata *library.Metadata, db *database.Database) error {
-	err := FixMetadata(metadata)
-	if err != nil {
-		return err
-	}
-
-	dbAlbum, err := db.GetAlbumById(ctx, metadata.Album.Id)
+// isAlbumUnchanged reports if the album was synced before with the same
+// fingerprint, unchanged albums and their tracks are marked as seen
+func (helper *SyncHelper) isAlbumUnchanged(ctx context.Context, db *database.Database, album *library.Album) (bool, error) {
+	dbAlbum, err := db.GetAlbumByIdWithTrashed(ctx, album.Metadata.Album.Id)
 	if err != nil {
 		if errors.Is(err, database.ErrItemNotFound) {
-			artist, err := helper.getOrCreateArtist(ctx, db, metadata.Album.Artists[0])
-			if err != nil {
-				return fmt.Errorf("failed to create artist for album: %w", err)
-			}
-
-			dbAlbum, err = db.CreateAlbum(ctx, database.CreateAlbumParams{
-				Id:       metadata.Album.Id,
-				Name:     metadata.Album.Name,
-				ArtistId: artist,
-			})
-			if err != nil {
-				return fmt.Errorf("failed to create album: %w", err)
-			}
-		} else {
-			return err
+			return false, nil
 		}
-	}
-
-	helper.albums[dbAlbum.Id] = struct{}{}
-
-	changes := database.AlbumChanges{}
-
-	// TODO(patrik): More updates
 
-	changes.Name = types.Change[string]{
-		Value:   metadata.Album.Name,
-		Changed: metadata.Album.Name != dbAlbum.Name,
+		return false, err
 	}
 
-	artist, err := helper.getOrCreateArtist(ctx, db, metadata.Album.Artists[0])
-	if err != nil {
-		return fmt.Errorf("failed to create artist for album: %w", err)
+	if !dbAlbum.SyncFingerprint.Valid || dbAlbum.SyncFingerprint.String != album.Fingerprint {
+		return false, nil
 	}
 
-	changes.ArtistId = types.Change[string]{
-		Value:   artist,
-		Changed: artist != dbAlbum.ArtistId,
+	// NOTE: Albums inside the trash needs to be synced to be restored
+	if dbAlbum.Trashed.Valid {
+		return false, nil
 	}
 
-	changes.CoverArt = types.Change[sql.NullString]{
-		Value: sql.NullString{
-			String: metadata.General.Cover,
-			Valid:  metadata.General.Cover != "",
-		},
-		Changed: metadata.General.Cover != dbAlbum.CoverArt.String,
+	// NOTE: Albums moved between the library roots needs to be synced to
+	// update the root
+	if dbAlbum.LibraryRoot.String != album.Root {
+		return false, nil
 	}
 
-	changes.Year = types.Change[sql.NullInt64]{
-		Value: sql.NullInt64{
-			Int64: metadata.Album.Year,
-			Valid: metadata.Album.Year != 0,
-		},
-		Changed: metadata.Album.Year != dbAlbum.Year.Int64,
-	}
+	helper.markAlbum(dbAlbum.Id)
 
-	err = db.UpdateAlbum(ctx, dbAlbum.Id, changes)
-	if err != nil {
-		return fmt.Errorf("failed to update album: %w", err)
+	for _, track := range album.Metadata.Tracks {
+		helper.markTrack(track.Id)
 	}
 
-	err = helper.setAlbumFeaturingArtists(
-		ctx,
-		db,
-		dbAlbum.Id,
-		metadata.Album.Artists[1:],
-	)
-	if err != nil {
-		return fmt.Errorf("failed to set album featuring artists: %w", err)
-	}
+	return true, nil
+}
 
-	err = helper.setAlbumTags(
-		ctx,
-		db,
-		dbAlbum.Id,
-		metadata.Album.Tags,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to set album tags: %w", err)
+// trackProbes is the result of probeTracks
+type trackProbes struct {
+	// files is the probe results by the track file
+	files map[string]utils.ProbeResult
+	// hashes is the new content hashes by the track id
+	hashes map[string]string
+}
+
+// probeTracks probes and hashes the track files that are new or modified
+// since the last sync, this runs outside of the transaction so multiple
+// albums can be probed in parallel. The files inside `loaded` was probed
+// when the album was loaded and isn't probed again.
+func (helper *SyncHelper) probeTracks(ctx context.Context, db *database.Database, metadata *library.Metadata, loaded map[string]utils.ProbeResult) (trackProbes, error) {
+	probes := trackProbes{
+		files:  map[string]utils.ProbeResult{},
+		hashes: map[string]string{},
 	}
 
 	for i, track := range metadata.Tracks {
 		stat, err := os.Stat(track.File)
 		if err != nil {
-			return fmt.Errorf("failed to stat track[%d] file (%s): %w", i, track.File, err)
+			return trackProbes{}, fmt.Errorf("failed to stat track[%d] file (%s): %w", i, track.File, err)
 		}
 
-		modifiedTime := stat.ModTime().UnixMilli()
-
-		artist, err := helper.getOrCreateArtist(ctx, db, track.Artists[0])
-		if err != nil {
-			return fmt.Errorf("failed to set create artist for track[%d]: %w", i, err)
+		dbTrack, err := db.GetTrackByIdWithTrashed(ctx, track.Id)
+		if err != nil && !errors.Is(err, database.ErrItemNotFound) {
+			return trackProbes{}, err
 		}
 
-		dbTrack, err := db.GetTrackById(ctx, track.Id)
-		if err != nil {
-			if errors.Is(err, database.ErrItemNotFound) {
-				probeResult, err := utils.ProbeTrack(track.File)
-				if err != nil {
-					return fmt.Errorf("failed to probe track[%d] file (%s): %w", i, track.File, err)
-				}
-
-				trackId, err := db.CreateTrack(ctx, database.CreateTrackParams{
-					Id:           track.Id,
-					Filename:     track.File,
-					ModifiedTime: modifiedTime,
-					MediaType:    probeResult.MediaType,
-					Name:         track.Name,
-					OtherName:    sql.NullString{},
-					AlbumId:      dbAlbum.Id,
-					ArtistId:     artist,
-					Duration:     int64(probeResult.Duration),
-					Number: sql.NullInt64{
-						Int64: track.Number,
-						Valid: track.Number != 0,
-					},
-					Year: sql.NullInt64{
-						Int64: track.Year,
-						Valid: track.Year != 0,
-					},
-				})
-				if err != nil {
-					return fmt.Errorf("failed to create track[%d]: %w", i, err)
-				}
-
-				helper.tracks[trackId] = struct{}{}
+		exists := err == nil
+		modified := !exists || stat.ModTime().UnixMilli() > dbTrack.ModifiedTime
 
-				err = helper.setTrackFeaturingArtists(
-					ctx,
-					db,
-					trackId,
-					track.Artists[1:],
-				)
-				if err != nil {
-					return fmt.Errorf("failed to set track[%d] featuring artists: %w", i, err)
-				}
+		// NOTE: Tracks synced before the audio properties was added is
+		// probed again, the migration clears the album fingerprints so
+		// the albums aren't skipped
+		needsProbe := modified || !dbTrack.Codec.Valid
 
-				err = helper.setTrackTags(ctx, db, trackId, track.Tags)
-				if err != nil {
-					return fmt.Errorf("failed to set track[%d] tags: %w", i, err)
-				}
+		// NOTE: The hash depends on the range, tracks synced before the
+		// hashes was added is also hashed
+		needsHash := modified ||
+			!dbTrack.ContentHash.Valid ||
+			rangeMillis(track.Start) != dbTrack.RangeStart ||
+			rangeMillis(track.End) != dbTrack.RangeEnd
 
-				continue
+		if needsHash {
+			hash, err := utils.ContentHash(track.File, track.Start, track.End)
+			if err != nil {
+				return trackProbes{}, fmt.Errorf("failed to hash track[%d] file (%s): %w", i, track.File, err)
 			}
+
+			probes.hashes[track.Id] = hash
 		}
 
-		helper.tracks[dbTrack.Id] = struct{}{}
+		// NOTE: Multiple tracks can use the same file with different
+		// ranges, the file only needs to be probed once
+		if _, probed := probes.files[track.File]; probed || !needsProbe {
+			continue
+		}
 
-		err = helper.setTrackFeaturingArtists(
-			ctx,
-			db,
-			dbTrack.Id,
-			track.Artists[1:],
-		)
-		if err != nil {
-			return fmt.Errorf("failed to set track[%d] featuring artists: %w", i, err)
+		if probeResult, exists := loaded[track.File]; exists {
+			probes.files[track.File] = probeResult
+			continue
 		}
 
-		err = helper.setTrackTags(ctx, db, dbTrack.Id, track.Tags)
+		probeResult, err := utils.ProbeTrack(track.File)
 		if err != nil {
-			return fmt.Errorf("failed to set track[%d] tags: %w", i, err)
+			return trackProbes{}, fmt.Errorf("failed to probe track[%d] file (%s): %w", i, track.File, err)
 		}
 
-		// TODO(patrik): Check modified time and probe again
-		// TODO(patrik): Update track
+		probes.files[track.File] = probeResult
+	}
 
-		changes := database.TrackChanges{}
+	return probes, nil
+}
 
-		if modifiedTime > dbTrack.ModifiedTime {
-			probeResult, err := utils.ProbeTrack(track.File)
-			if err != nil {
-				return fmt.Errorf("failed to probe track[%d] file (%s): %w", i, track.File, err)
-			}
+func probeTrack(probes trackProbes, file string) (utils.ProbeResult, error) {
+	if probeResult, exists := probes.files[file]; exists {
+		return probeResult, nil
+	}
 
-			dur := int64(probeResult.Duration)
-			changes.Duration = types.Change[int64]{
-				Value:   dur,
-				Changed: dur != dbTrack.Duration,
-			}
+	return utils.ProbeTrack(file)
+}
 
-			changes.MediaType = types.Change[types.MediaType]{
-				Value:   probeResult.MediaType,
-				Changed: probeResult.MediaType != dbTrack.MediaType,
-			}
+// rangeMillis converts a range value from the metadata in seconds to the
+// milliseconds stored inside the database, 0 is stored as not set
+func rangeMillis(seconds float64) sql.NullInt64 {
+	ms := int64(math.Round(seconds * 1000))
 
-			changes.ModifiedTime = types.Change[int64]{
-				Value:   modifiedTime,
-				Changed: modifiedTime != dbTrack.ModifiedTime,
-			}
-		}
+	return sql.NullInt64{
+		Int64: ms,
+		Valid: ms != 0,
+	}
+}
 
-		// TODO(patrik): Implement all the changes here
+// probeInt64 converts a technical property from the probe to the database
+// value, 0 is stored as unknown
+func probeInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{
+		Int64: v,
+		Valid: v != 0,
+	}
+}
 
-		changes.Filename = types.Change[string]{
-			Value:   track.File,
-			Changed: track.File != dbTrack.Filename,
-		}
+// setAudioChanges sets the changes for the technical properties of the
+// audio from the probe
+func setAudioChanges(changes *database.TrackChanges, probeResult utils.ProbeResult, dbTrack *database.Track) {
+	setInt := func(change *types.Change[sql.NullInt64], v int64, old sql.NullInt64) {
+		value := probeInt64(v)
 
-		changes.Name = types.Change[string]{
-			Value:   track.Name,
-			Changed: track.Name != dbTrack.Name,
+		*change = types.Change[sql.NullInt64]{
+			Value:   value,
+			Changed: value != old,
 		}
+	}
 
-		changes.ArtistId = types.Change[string]{
-			Value:   artist,
-			Changed: artist != dbTrack.ArtistId,
-		}
+	setInt(&changes.SampleRate, probeResult.SampleRate, dbTrack.SampleRate)
+	setInt(&changes.BitDepth, probeResult.BitDepth, dbTrack.BitDepth)
+	setInt(&changes.Channels, probeResult.Channels, dbTrack.Channels)
+	setInt(&changes.BitRate, probeResult.BitRate, dbTrack.BitRate)
+	setInt(&changes.FileSize, probeResult.FileSize, dbTrack.FileSize)
 
-		changes.Number = types.Change[sql.NullInt64]{
-			Value: sql.NullInt64{
-				Int64: track.Number,
-				Valid: track.Number != 0,
-			},
-			Changed: track.Number != dbTrack.Number.Int64,
-		}
+	codec := sql.NullString{
+		String: probeResult.Codec,
+		Valid:  probeResult.Codec != "",
+	}
+
+	changes.Codec = types.Change[sql.NullString]{
+		Value:   codec,
+		Changed: codec != dbTrack.Codec,
+	}
+}
+
+// trackDuration returns the duration of the track in seconds, for tracks
+// with a range only the range is counted
+func trackDuration(track *library.MetadataTrack, probeResult utils.ProbeResult) int64 {
+	end := probeResult.Duration
+	if track.End != 0 && track.End < end {
+		end = track.End
+	}
+
+	return max(int64(end-track.Start), 0)
+}
+
+// syncAlbumTx syncs the album inside a transaction, so the album is either
+// fully updated or not touched at all
+func (helper *SyncHelper) syncAlbumTx(ctx context.Context, db *database.Database, album *library.Album, probes trackProbes) error {
+	helper.writeMutex.Lock()
+	defer helper.writeMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	committed := false
+	defer func() {
+		if !committed {
+			helper.resetArtists()
+		}
+	}()
+
+	var stats SyncStats
+
+	err = helper.syncAlbum(ctx, &tx, &album.Metadata, probes, &stats)
+	if err != nil {
+		return err
+	}
+
+	err = tx.UpdateAlbumSyncFingerprint(ctx, album.Metadata.Album.Id, sql.NullString{
+		String: album.Fingerprint,
+		Valid:  album.Fingerprint != "",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.UpdateAlbumLibraryRoot(ctx, album.Metadata.Album.Id, sql.NullString{
+		String: album.Root,
+		Valid:  album.Root != "",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	committed = true
+	helper.addStats(stats)
+
+	return nil
+}
+
+// processAlbum syncs the album if it has changed, returns true if the
+// album was skipped because it was unchanged
+func (helper *SyncHelper) processAlbum(ctx context.Context, db *database.Database, album *library.Album, options SyncOptions) (bool, error) {
+	if !options.Force {
+		unchanged, err := helper.isAlbumUnchanged(ctx, db, album)
+		if err != nil {
+			return false, err
+		}
+
+		if unchanged {
+			slog.Debug("Skipping unchanged album", "path", album.Path)
+
+			helper.addStats(SyncStats{
+				NumUnchangedAlbums: 1,
+				NumUnchangedTracks: len(album.Metadata.Tracks),
+			})
+
+			return true, nil
+		}
+	}
 
-		changes.Year = types.Change[sql.NullInt64]{
-			Value: sql.NullInt64{
-				Int64: track.Year,
-				Valid: track.Year != 0,
+	slog.Debug("Syncing album", "path", album.Path)
+
+	loaded, err := album.Load()
+	if err != nil {
+		return false, err
+	}
+
+	probes, err := helper.probeTracks(ctx, db, &album.Metadata, loaded)
+	if err != nil {
+		return false, err
+	}
+
+	return false, helper.syncAlbumTx(ctx, db, album, probes)
+}
+
+// createArtistChanges creates the changes needed to make the database
+// artist match the metadata
+func createArtistChanges(artist *library.MetadataArtist, dbArtist *database.Artist) database.ArtistChanges {
+	changes := database.ArtistChanges{}
+
+	if artist.OtherName != "" {
+		changes.OtherName = types.Change[sql.NullString]{
+			Value: sql.NullString{
+				String: artist.OtherName,
+				Valid:  true,
 			},
-			Changed: track.Year != dbTrack.Year.Int64,
+			Changed: artist.OtherName != dbArtist.OtherName.String,
 		}
+	}
 
-		err = db.UpdateTrack(ctx, dbTrack.Id, changes)
+	return changes
+}
+
+// createAlbumChanges creates the changes needed to make the database album
+// match the metadata
+func createAlbumChanges(metadata *library.Metadata, dbAlbum *database.Album, artistId string) database.AlbumChanges {
+	changes := database.AlbumChanges{}
+
+	// TODO(patrik): More updates
+
+	changes.Name = types.Change[string]{
+		Value:   metadata.Album.Name,
+		Changed: metadata.Album.Name != dbAlbum.Name,
+	}
+
+	changes.OtherName = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: metadata.Album.OtherName,
+			Valid:  metadata.Album.OtherName != "",
+		},
+		Changed: metadata.Album.OtherName != dbAlbum.OtherName.String,
+	}
+
+	changes.ArtistId = types.Change[string]{
+		Value:   artistId,
+		Changed: artistId != dbAlbum.ArtistId,
+	}
+
+	changes.CoverArt = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: metadata.General.Cover,
+			Valid:  metadata.General.Cover != "",
+		},
+		Changed: metadata.General.Cover != dbAlbum.CoverArt.String,
+	}
+
+	changes.Year = types.Change[sql.NullInt64]{
+		Value: sql.NullInt64{
+			Int64: metadata.Album.Year,
+			Valid: metadata.Album.Year != 0,
+		},
+		Changed: metadata.Album.Year != dbAlbum.Year.Int64,
+	}
+
+	changes.ReleaseDate = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: metadata.Album.ReleaseDate,
+			Valid:  metadata.Album.ReleaseDate != "",
+		},
+		Changed: metadata.Album.ReleaseDate != dbAlbum.ReleaseDate.String,
+	}
+
+	changes.OriginalReleaseDate = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: metadata.Album.OriginalReleaseDate,
+			Valid:  metadata.Album.OriginalReleaseDate != "",
+		},
+		Changed: metadata.Album.OriginalReleaseDate != dbAlbum.OriginalReleaseDate.String,
+	}
+
+	releaseType := types.ReleaseType(metadata.Album.ReleaseType)
+	changes.ReleaseType = types.Change[types.ReleaseType]{
+		Value:   releaseType,
+		Changed: releaseType != dbAlbum.ReleaseType,
+	}
+
+	// NOTE: Restore the album if it was moved to the trash
+	changes.Trashed = types.Change[sql.NullInt64]{
+		Value:   sql.NullInt64{},
+		Changed: dbAlbum.Trashed.Valid,
+	}
+
+	return changes
+}
+
+// readTrackLyrics reads the lyrics for the track, the lyrics is not set if
+// the track has no lyrics
+func readTrackLyrics(track *library.MetadataTrack) (sql.NullString, error) {
+	lyrics, err := library.ReadLyrics(track)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{
+		String: lyrics,
+		Valid:  lyrics != "",
+	}, nil
+}
+
+// setLyricsChanges sets the lyrics change, the current lyrics is only
+// fetched if the track has lyrics because it's not part of the track query
+func setLyricsChanges(ctx context.Context, db database.DB, changes *database.TrackChanges, lyrics sql.NullString, dbTrack *database.Track) error {
+	var current sql.NullString
+	if dbTrack.HasLyrics {
+		var err error
+		current, err = db.GetTrackLyrics(ctx, dbTrack.Id)
 		if err != nil {
-			return fmt.Errorf("failed to update track[%d]: %w", i, err)
+			return err
+		}
+	}
+
+	changes.Lyrics = types.Change[sql.NullString]{
+		Value:   lyrics,
+		Changed: lyrics != current,
+	}
+
+	return nil
+}
+
+// setTrackChanges sets the changes needed to make the database track match
+// the metadata, the changes from probing the file is handled separately
+func setTrackChanges(changes *database.TrackChanges, track *library.MetadataTrack, dbTrack *database.Track, artistId string) {
+	// TODO(patrik): Implement all the changes here
+
+	changes.Filename = types.Change[string]{
+		Value:   track.File,
+		Changed: track.File != dbTrack.Filename,
+	}
+
+	changes.Name = types.Change[string]{
+		Value:   track.Name,
+		Changed: track.Name != dbTrack.Name,
+	}
+
+	changes.OtherName = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: track.OtherName,
+			Valid:  track.OtherName != "",
+		},
+		Changed: track.OtherName != dbTrack.OtherName.String,
+	}
+
+	changes.ArtistId = types.Change[string]{
+		Value:   artistId,
+		Changed: artistId != dbTrack.ArtistId,
+	}
+
+	changes.Number = types.Change[sql.NullInt64]{
+		Value: sql.NullInt64{
+			Int64: track.Number,
+			Valid: track.Number != 0,
+		},
+		Changed: track.Number != dbTrack.Number.Int64,
+	}
+
+	changes.Year = types.Change[sql.NullInt64]{
+		Value: sql.NullInt64{
+			Int64: track.Year,
+			Valid: track.Year != 0,
+		},
+		Changed: track.Year != dbTrack.Year.Int64,
+	}
+
+	changes.ReleaseDate = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: track.ReleaseDate,
+			Valid:  track.ReleaseDate != "",
+		},
+		Changed: track.ReleaseDate != dbTrack.ReleaseDate.String,
+	}
+
+	changes.OriginalReleaseDate = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: track.OriginalReleaseDate,
+			Valid:  track.OriginalReleaseDate != "",
+		},
+		Changed: track.OriginalReleaseDate != dbTrack.OriginalReleaseDate.String,
+	}
+
+	changes.Disc = types.Change[sql.NullInt64]{
+		Value: sql.NullInt64{
+			Int64: track.Disc,
+			Valid: track.Disc != 0,
+		},
+		Changed: track.Disc != dbTrack.Disc.Int64,
+	}
+
+	changes.DiscSubtitle = types.Change[sql.NullString]{
+		Value: sql.NullString{
+			String: track.DiscSubtitle,
+			Valid:  track.DiscSubtitle != "",
+		},
+		Changed: track.DiscSubtitle != dbTrack.DiscSubtitle.String,
+	}
+
+	rangeStart := rangeMillis(track.Start)
+	changes.RangeStart = types.Change[sql.NullInt64]{
+		Value:   rangeStart,
+		Changed: rangeStart.Int64 != dbTrack.RangeStart.Int64,
+	}
+
+	rangeEnd := rangeMillis(track.End)
+	changes.RangeEnd = types.Change[sql.NullInt64]{
+		Value:   rangeEnd,
+		Changed: rangeEnd.Int64 != dbTrack.RangeEnd.Int64,
+	}
+
+	// NOTE: Restore the track if it was moved to the trash
+	changes.Trashed = types.Change[sql.NullInt64]{
+		Value:   sql.NullInt64{},
+		Changed: dbTrack.Trashed.Valid,
+	}
+}
+
+// TODO(patrik): Update the errors for album
+func (helper *SyncHelper) syncAlbum(ctx context.Context, db *database.Tx, metadata *library.Metadata, probes trackProbes, stats *SyncStats) error {
+	err := FixMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = helper.setArtistInfo(ctx, db, metadata.Artists)
+	if err != nil {
+		return fmt.Errorf("failed to set artist info: %w", err)
+	}
+
+	dbAlbum, err := db.GetAlbumByIdWithTrashed(ctx, metadata.Album.Id)
+	if err != nil {
+		if errors.Is(err, database.ErrItemNotFound) {
+			artist, err := helper.getOrCreateArtist(ctx, db, metadata.Album.Artists[0])
+			if err != nil {
+				return fmt.Errorf("failed to create artist for album: %w", err)
+			}
+
+			dbAlbum, err = db.CreateAlbum(ctx, database.CreateAlbumParams{
+				Id:   metadata.Album.Id,
+				Name: metadata.Album.Name,
+				OtherName: sql.NullString{
+					String: metadata.Album.OtherName,
+					Valid:  metadata.Album.OtherName != "",
+				},
+				ArtistId:    artist,
+				ReleaseType: types.ReleaseType(metadata.Album.ReleaseType),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create album: %w", err)
+			}
+
+			stats.NumCreatedAlbums++
+		} else {
+			return err
+		}
+	} else {
+		stats.NumUpdatedAlbums++
+	}
+
+	helper.markAlbum(dbAlbum.Id)
+
+	artist, err := helper.getOrCreateArtist(ctx, db, metadata.Album.Artists[0])
+	if err != nil {
+		return fmt.Errorf("failed to create artist for album: %w", err)
+	}
+
+	changes := createAlbumChanges(metadata, &dbAlbum, artist)
+
+	err = db.UpdateAlbum(ctx, dbAlbum.Id, changes)
+	if err != nil {
+		return fmt.Errorf("failed to update album: %w", err)
+	}
+
+	err = helper.setAlbumFeaturingArtists(
+		ctx,
+		db,
+		dbAlbum.Id,
+		metadata.Album.Artists[1:],
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set album featuring artists: %w", err)
+	}
+
+	err = helper.setAlbumTags(
+		ctx,
+		db,
+		dbAlbum.Id,
+		metadata.Album.Tags,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set album tags: %w", err)
+	}
+
+	for i, track := range metadata.Tracks {
+		stat, err := os.Stat(track.File)
+		if err != nil {
+			return fmt.Errorf("failed to stat track[%d] file (%s): %w", i, track.File, err)
+		}
+
+		modifiedTime := stat.ModTime().UnixMilli()
+
+		lyrics, err := readTrackLyrics(&track)
+		if err != nil {
+			return fmt.Errorf("failed to read track[%d] lyrics: %w", i, err)
+		}
+
+		artist, err := helper.getOrCreateArtist(ctx, db, track.Artists[0])
+		if err != nil {
+			return fmt.Errorf("failed to set create artist for track[%d]: %w", i, err)
+		}
+
+		dbTrack, err := db.GetTrackByIdWithTrashed(ctx, track.Id)
+		if err != nil {
+			if errors.Is(err, database.ErrItemNotFound) {
+				probeResult, err := probeTrack(probes, track.File)
+				if err != nil {
+					return fmt.Errorf("failed to probe track[%d] file (%s): %w", i, track.File, err)
+				}
+
+				trackId, err := db.CreateTrack(ctx, database.CreateTrackParams{
+					Id:           track.Id,
+					Filename:     track.File,
+					ModifiedTime: modifiedTime,
+					MediaType:    probeResult.MediaType,
+					Name:         track.Name,
+					OtherName: sql.NullString{
+						String: track.OtherName,
+						Valid:  track.OtherName != "",
+					},
+					AlbumId:    dbAlbum.Id,
+					ArtistId:   artist,
+					Duration:   trackDuration(&track, probeResult),
+					SampleRate: probeInt64(probeResult.SampleRate),
+					BitDepth:   probeInt64(probeResult.BitDepth),
+					Channels:   probeInt64(probeResult.Channels),
+					BitRate:    probeInt64(probeResult.BitRate),
+					Codec: sql.NullString{
+						String: probeResult.Codec,
+						Valid:  probeResult.Codec != "",
+					},
+					FileSize: probeInt64(probeResult.FileSize),
+					Number: sql.NullInt64{
+						Int64: track.Number,
+						Valid: track.Number != 0,
+					},
+					Year: sql.NullInt64{
+						Int64: track.Year,
+						Valid: track.Year != 0,
+					},
+					ReleaseDate: sql.NullString{
+						String: track.ReleaseDate,
+						Valid:  track.ReleaseDate != "",
+					},
+					OriginalReleaseDate: sql.NullString{
+						String: track.OriginalReleaseDate,
+						Valid:  track.OriginalReleaseDate != "",
+					},
+					Disc: sql.NullInt64{
+						Int64: track.Disc,
+						Valid: track.Disc != 0,
+					},
+					DiscSubtitle: sql.NullString{
+						String: track.DiscSubtitle,
+						Valid:  track.DiscSubtitle != "",
+					},
+					RangeStart: rangeMillis(track.Start),
+					RangeEnd:   rangeMillis(track.End),
+					Lyrics:     lyrics,
+					ContentHash: sql.NullString{
+						String: probes.hashes[track.Id],
+						Valid:  probes.hashes[track.Id] != "",
+					},
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create track[%d]: %w", i, err)
+				}
+
+				helper.markTrack(trackId)
+				stats.NumCreatedTracks++
+
+				err = helper.setTrackFeaturingArtists(
+					ctx,
+					db,
+					trackId,
+					track.Artists[1:],
+				)
+				if err != nil {
+					return fmt.Errorf("failed to set track[%d] featuring artists: %w", i, err)
+				}
+
+				err = helper.setTrackTags(ctx, db, trackId, track.Tags)
+				if err != nil {
+					return fmt.Errorf("failed to set track[%d] tags: %w", i, err)
+				}
+
+				continue
+			}
+		}
+
+		helper.markTrack(dbTrack.Id)
+		stats.NumUpdatedTracks++
+
+		err = helper.setTrackFeaturingArtists(
+			ctx,
+			db,
+			dbTrack.Id,
+			track.Artists[1:],
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set track[%d] featuring artists: %w", i, err)
+		}
+
+		err = helper.setTrackTags(ctx, db, dbTrack.Id, track.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to set track[%d] tags: %w", i, err)
+		}
+
+		changes := database.TrackChanges{}
+
+		setTrackChanges(&changes, &track, &dbTrack, artist)
+
+		err = setLyricsChanges(ctx, db.DB, &changes, lyrics, &dbTrack)
+		if err != nil {
+			return fmt.Errorf("failed to get track[%d] lyrics: %w", i, err)
+		}
+
+		if hash, exists := probes.hashes[track.Id]; exists {
+			changes.ContentHash = types.Change[sql.NullString]{
+				Value: sql.NullString{
+					String: hash,
+					Valid:  true,
+				},
+				Changed: hash != dbTrack.ContentHash.String,
+			}
+		}
+
+		// NOTE: The duration depends on the range, so the file needs to be
+		// probed again if the range changed
+		rangeChanged := changes.RangeStart.Changed || changes.RangeEnd.Changed
+		audioChanged := modifiedTime > dbTrack.ModifiedTime || rangeChanged
+
+		if audioChanged || !dbTrack.Codec.Valid {
+			probeResult, err := probeTrack(probes, track.File)
+			if err != nil {
+				return fmt.Errorf("failed to probe track[%d] file (%s): %w", i, track.File, err)
+			}
+
+			dur := trackDuration(&track, probeResult)
+			changes.Duration = types.Change[int64]{
+				Value:   dur,
+				Changed: dur != dbTrack.Duration,
+			}
+
+			changes.MediaType = types.Change[types.MediaType]{
+				Value:   probeResult.MediaType,
+				Changed: probeResult.MediaType != dbTrack.MediaType,
+			}
+
+			changes.ModifiedTime = types.Change[int64]{
+				Value:   modifiedTime,
+				Changed: modifiedTime != dbTrack.ModifiedTime,
+			}
+
+			setAudioChanges(&changes, probeResult, &dbTrack)
+		}
+
+		// NOTE: The audio changed so the loudness needs to be analysed
+		// again, the old gain is cleared so it's not applied to the new
+		// audio before the analysis
+		if audioChanged {
+			changes.LoudnessAnalyzed = types.Change[sql.NullInt64]{
+				Value:   sql.NullInt64{},
+				Changed: dbTrack.LoudnessAnalyzed.Valid,
+			}
+
+			changes.TrackGain = types.Change[sql.NullFloat64]{Changed: dbTrack.TrackGain.Valid}
+			changes.TrackPeak = types.Change[sql.NullFloat64]{Changed: dbTrack.TrackPeak.Valid}
+			changes.AlbumGain = types.Change[sql.NullFloat64]{Changed: dbTrack.AlbumGain.Valid}
+			changes.AlbumPeak = types.Change[sql.NullFloat64]{Changed: dbTrack.AlbumPeak.Valid}
+		}
+
+		err = db.UpdateTrack(ctx, dbTrack.Id, changes)
+		if err != nil {
+			return fmt.Errorf("failed to update track[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+type ReportType string
+
+const (
+	ReportTypeSearch ReportType = "search"
+	ReportTypeSync   ReportType = "sync"
+
+	ReportTypeDuplicate  ReportType = "duplicate"
+	ReportTypeValidation ReportType = "validation"
+)
+
+type SyncError struct {
+	Type        ReportType `json:"type"`
+	Message     string     `json:"message"`
+	FullMessage *string    `json:"fullMessage,omitempty"`
+
+	// Paths is the albums involved in the error, used by duplicate errors
+	Paths []string `json:"paths,omitempty"`
+
+	// Location of the error, used by validation errors
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+type MissingAlbum struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	ArtistName string `json:"artistName"`
+}
+
+type MissingTrack struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	AlbumName  string `json:"albumName"`
+	ArtistName string `json:"artistName"`
+}
+
+type SyncHandler struct {
+	broker *Broker
+
+	mutex sync.RWMutex
+
+	isSyncing        atomic.Bool
+	isRetrivingPaths atomic.Bool
+
+	// pathsDirty is set when the paths needs to be retrived again, a
+	// request during a walk runs the walk again after it's done
+	pathsDirty atomic.Bool
+
+	libraryRoot atomic.Pointer[[]Path]
+
+	syncErrors    []SyncError
+	missingAlbums []MissingAlbum
+	missingTracks []MissingTrack
+	orphans       *CollectOrphans
+}
+
+type Report struct {
+	SyncErrors    []SyncError    `json:"syncErrors"`
+	MissingAlbums []MissingAlbum `json:"missingAlbums"`
+	MissingTracks []MissingTrack `json:"missingTracks"`
+
+	// Orphans is the deleted artists and tags, only set if the orphans
+	// was collected after the sync
+	Orphans *CollectOrphans `json:"orphans,omitempty"`
+}
+
+func (s *SyncHandler) GetReport() Report {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return Report{
+		SyncErrors:    s.syncErrors,
+		MissingAlbums: s.missingAlbums,
+		MissingTracks: s.missingTracks,
+		Orphans:       s.orphans,
+	}
+}
+
+// albumErrors returns the validation errors from the last sync keyed by
+// the absolute path of the album.toml
+func (s *SyncHandler) albumErrors() map[string]string {
+	report := s.GetReport()
+
+	res := map[string]string{}
+	for _, e := range report.SyncErrors {
+		if e.Type != ReportTypeValidation || e.File == "" {
+			continue
+		}
+
+		p, err := filepath.Abs(e.File)
+		if err != nil {
+			continue
+		}
+
+		if prev, exists := res[p]; exists {
+			res[p] = prev + "\n" + e.Message
+		} else {
+			res[p] = e.Message
+		}
+	}
+
+	return res
+}
+
+func (s *SyncHandler) retrivePaths(app core.App) error {
+	slog.Info("Getting paths")
+
+	albumErrors := s.albumErrors()
+
+	var paths []Path
+	for _, root := range app.Config().LibraryRoots() {
+		node, err := library.BuildDirTree(root.Path, albumErrors)
+		if err != nil {
+			return err
+		}
+
+		paths = append(paths, flattenTree(root.Name, node, 0)...)
+	}
+
+	slog.Info("Done getting paths")
+
+	s.libraryRoot.Store(&paths)
+
+	return nil
+}
+
+// RetrivePaths walks the library roots in the background, if a walk is
+// already running the walk is run again after it's done so the changes
+// made during the walk is picked up
+func (s *SyncHandler) RetrivePaths(app core.App) {
+	s.pathsDirty.Store(true)
+
+	if !s.isRetrivingPaths.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		for {
+			s.EmitState()
+
+			for s.pathsDirty.Swap(false) {
+				err := s.retrivePaths(app)
+				if err != nil {
+					slog.Error("Failed to retrive paths", "err", err)
+				}
+			}
+
+			s.isRetrivingPaths.Store(false)
+			s.EmitState()
+
+			// NOTE: A request could have come in after the last walk but
+			// before the flag was cleared
+			if !s.pathsDirty.Load() || !s.isRetrivingPaths.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}()
+}
+
+// Cleanup moves the missing albums and tracks to the trash and purges the
+// items that has been inside the trash longer then the retention period,
+// the trashed items is restored by the sync if the files comes back.
+// Returns ErrSyncInProgress if the library is syncing.
+func (s *SyncHandler) Cleanup(app core.App) error {
+	return s.runExclusive(func() error {
+		return s.cleanup(app)
+	})
+}
+
+func (s *SyncHandler) cleanup(app core.App) error {
+	tx, err := app.DB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ctx := context.TODO()
+
+	report := s.GetReport()
+
+	for _, track := range report.MissingTracks {
+		err := tx.TrashTrack(ctx, track.Id)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Moved track to trash", "track", track)
+	}
+
+	for _, album := range report.MissingAlbums {
+		err := tx.TrashAlbum(ctx, album.Id)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Moved album to trash", "album", album)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	err = purgeExpiredTrash(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.missingAlbums = []MissingAlbum{}
+	s.missingTracks = []MissingTrack{}
+	s.mutex.Unlock()
+
+	// NOTE: The state reads the report with the lock held, so the state
+	// is emitted after the lock is released
+	s.EmitState()
+
+	return nil
+}
+
+func (s *SyncHandler) GetStateEvent() SyncStateEvent {
+	p := s.libraryRoot.Load()
+
+	paths := []Path{}
+	if p != nil {
+		paths = *p
+	}
+
+	return SyncStateEvent{
+		IsSyncing:        s.isSyncing.Load(),
+		IsRetrivingPaths: s.isRetrivingPaths.Load(),
+		Paths:            paths,
+		Report:           s.GetReport(),
+	}
+}
+
+func (s *SyncHandler) EmitState() {
+	s.broker.EmitEvent(s.GetStateEvent())
+}
+
+// syncTarget is a library root and the path inside the root to sync
+type syncTarget struct {
+	root config.LibraryRoot
+
+	// first is set for the first library root, the albums synced before
+	// the roots was added belongs to the first root
+	first bool
+
+	fullPath string
+	isRoot   bool
+}
+
+func (t *syncTarget) searchOptions(app core.App) library.SearchOptions {
+	// NOTE: The first root keeps the tagged ids from before the roots was
+	// added, the other roots uses the name to keep the ids unique
+	prefix := ""
+	if !t.first {
+		prefix = t.root.Name + ":"
+	}
+
+	return library.SearchOptions{
+		ImportTagged:   app.Config().ImportTagged,
+		Root:           t.root.Path,
+		RootName:       t.root.Name,
+		TaggedIdPrefix: prefix,
+	}
+}
+
+// resolveSyncTargets returns the library roots and paths to sync for `p`
+// inside `rootName`, an empty root name syncs every root when `p` is the
+// root and the first root otherwise
+func resolveSyncTargets(app core.App, rootName, p string) ([]syncTarget, error) {
+	var isRoot bool
+	if p == "" || p == "/" {
+		isRoot = true
+		p = ""
+	}
+
+	roots := app.Config().LibraryRoots()
+
+	var res []syncTarget
+	for i, root := range roots {
+		if rootName == "" {
+			if !isRoot && i > 0 {
+				break
+			}
+		} else if root.Name != rootName {
+			continue
+		}
+
+		res = append(res, syncTarget{
+			root:     root,
+			first:    i == 0,
+			fullPath: path.Join(root.Path, p),
+			isRoot:   isRoot,
+		})
+	}
+
+	if len(res) == 0 {
+		return nil, LibraryRootNotFound()
+	}
+
+	return res, nil
+}
+
+// librarySearch is the albums and artists found inside the sync targets
+type librarySearch struct {
+	albums  []library.Album
+	artists []library.Artist
+	errors  map[string]error
+}
+
+func searchTargets(app core.App, targets []syncTarget) (librarySearch, error) {
+	res := librarySearch{
+		errors: map[string]error{},
+	}
+
+	for _, target := range targets {
+		slog.Debug("Searching for albums", "root", target.root.Name, "path", target.fullPath)
+
+		search, err := library.FindAlbums(target.fullPath, target.searchOptions(app))
+		if err != nil {
+			return librarySearch{}, err
+		}
+
+		artistSearch, err := library.FindArtists(target.fullPath, target.root.Path)
+		if err != nil {
+			return librarySearch{}, err
+		}
+
+		res.albums = append(res.albums, search.Albums...)
+		res.artists = append(res.artists, artistSearch.Artists...)
+
+		for p, err := range search.Errors {
+			res.errors[p] = err
+		}
+
+		for p, err := range artistSearch.Errors {
+			res.errors[p] = err
+		}
+	}
+
+	slog.Debug("Done searching for albums")
+
+	return res, nil
+}
+
+// findMissing returns the albums and tracks inside the database that
+// wasn't seen by the helper, only the albums synced from the fully
+// searched roots is checked
+func (helper *SyncHelper) findMissing(ctx context.Context, db *database.Database, targets []syncTarget) ([]MissingAlbum, []MissingTrack, error) {
+	var missingAlbums []MissingAlbum
+	var missingTracks []MissingTrack
+
+	var albumIds []string
+	var trackIds []string
+
+	for _, target := range targets {
+		if !target.isRoot {
+			continue
+		}
+
+		ids, err := db.GetAllAlbumIdsInRoot(ctx, target.root.Name, target.first)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		albumIds = append(albumIds, ids...)
+
+		ids, err = db.GetAllTrackIdsInRoot(ctx, target.root.Name, target.first)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		trackIds = append(trackIds, ids...)
+	}
+
+	for _, id := range albumIds {
+		if !helper.hasAlbum(id) {
+			album, err := db.GetAlbumById(ctx, id)
+			if err != nil {
+				// TODO(patrik): How should we handle the error?
+				continue
+			}
+
+			missingAlbums = append(missingAlbums, MissingAlbum{
+				Id:         id,
+				Name:       album.Name,
+				ArtistName: album.ArtistName,
+			})
+		}
+	}
+
+	for _, id := range trackIds {
+		if !helper.hasTrack(id) {
+			track, err := db.GetTrackById(ctx, id)
+			if err != nil {
+				// TODO(patrik): How should we handle the error?
+				continue
+			}
+
+			missingTracks = append(missingTracks, MissingTrack{
+				Id:         id,
+				Name:       track.Name,
+				AlbumName:  track.AlbumName,
+				ArtistName: track.ArtistName,
+			})
+		}
+	}
+
+	return missingAlbums, missingTracks, nil
+}
+
+func createSearchErrors(searchErrors map[string]error) []SyncError {
+	errs := make([]SyncError, 0, len(searchErrors))
+
+	for _, err := range searchErrors {
+		var validationErrors library.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			for _, e := range validationErrors {
+				var fullMessage *string
+				if e.FullMessage != "" {
+					m := e.FullMessage
+					fullMessage = &m
+				}
+
+				errs = append(errs, SyncError{
+					Type:        ReportTypeValidation,
+					Message:     e.Error(),
+					FullMessage: fullMessage,
+					File:        e.File,
+					Line:        e.Line,
+					Column:      e.Column,
+				})
+			}
+
+			continue
 		}
+
+		errs = append(errs, SyncError{
+			Type:    ReportTypeSearch,
+			Message: err.Error(),
+		})
 	}
 
-	return nil
+	return errs
 }
 
-type ReportType string
+// skipDuplicates removes the albums that share an album or track id with
+// another album, the skipped albums are marked as seen so they are not
+// reported as missing
+func (helper *SyncHelper) skipDuplicates(albums []library.Album) ([]*library.Album, []SyncError) {
+	duplicates := library.FindDuplicateIds(albums)
 
-const (
-	ReportTypeSearch ReportType = "search"
-	ReportTypeSync   ReportType = "sync"
-)
+	affected := map[string]struct{}{}
+	errs := make([]SyncError, 0, len(duplicates))
 
-type SyncError struct {
-	Type        ReportType `json:"type"`
-	Message     string     `json:"message"`
-	FullMessage *string    `json:"fullMessage,omitempty"`
-}
+	for _, duplicate := range duplicates {
+		affected[duplicate.Path] = struct{}{}
+		affected[duplicate.OtherPath] = struct{}{}
 
-type MissingAlbum struct {
-	Id         string `json:"id"`
-	Name       string `json:"name"`
-	ArtistName string `json:"artistName"`
-}
+		errs = append(errs, SyncError{
+			Type: ReportTypeDuplicate,
+			Message: fmt.Sprintf(
+				"duplicate %s id %q in %s and %s",
+				duplicate.Kind,
+				duplicate.Id,
+				duplicate.Path,
+				duplicate.OtherPath,
+			),
+			Paths: []string{duplicate.Path, duplicate.OtherPath},
+		})
+	}
 
-type MissingTrack struct {
-	Id         string `json:"id"`
-	Name       string `json:"name"`
-	AlbumName  string `json:"albumName"`
-	ArtistName string `json:"artistName"`
-}
+	res := make([]*library.Album, 0, len(albums))
 
-type SyncHandler struct {
-	broker *Broker
+	for i := range albums {
+		album := &albums[i]
 
-	mutex sync.RWMutex
+		if _, skip := affected[album.Path]; skip {
+			slog.Warn("Skipping album with duplicate ids", "path", album.Path)
 
-	isSyncing        atomic.Bool
-	isRetrivingPaths atomic.Bool
+			helper.markAlbum(album.Metadata.Album.Id)
+			for _, track := range album.Metadata.Tracks {
+				helper.markTrack(track.Id)
+			}
 
-	libraryRoot atomic.Pointer[[]Path]
+			continue
+		}
 
-	syncErrors    []SyncError
-	missingAlbums []MissingAlbum
-	missingTracks []MissingTrack
-}
+		res = append(res, album)
+	}
 
-type Report struct {
-	SyncErrors    []SyncError    `json:"syncErrors"`
-	MissingAlbums []MissingAlbum `json:"missingAlbums"`
-	MissingTracks []MissingTrack `json:"missingTracks"`
+	return res, errs
 }
 
-func (s *SyncHandler) GetReport() Report {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+type SyncOptions struct {
+	// Force syncs every album, even the ones with an unchanged fingerprint
+	Force bool
 
-	return Report{
-		SyncErrors:    s.syncErrors,
-		MissingAlbums: s.missingAlbums,
-		MissingTracks: s.missingTracks,
-	}
+	// NoBackgroundJobs skips the path refresh and the loudness analysis
+	// started after the sync, used when the process exits after the sync
+	NoBackgroundJobs bool
 }
 
-func (s *SyncHandler) RetrivePaths(app core.App) {
-	go func() {
-		if s.isRetrivingPaths.Load() {
-			slog.Warn("Already retriving paths")
-			return
-		}
-		
-		s.isRetrivingPaths.Store(true)
-		defer s.isRetrivingPaths.Store(false)
-
-		s.EmitState()
-
-		slog.Info("Getting paths")
-
-		root, err := library.BuildDirTree(app.Config().LibraryDir)
-		if err != nil {
-			// TODO(patrik): Handle error
-			return
-		}
+// ErrSyncInProgress is returned by RunSync when another sync is already
+// running
+var ErrSyncInProgress = errors.New("library is already syncing")
 
-		slog.Info("Done getting paths")
+// runExclusive runs `fn` while no sync can start, used by the library
+// writes outside of the sync. Returns ErrSyncInProgress if the library is
+// syncing.
+func (s *SyncHandler) runExclusive(fn func() error) error {
+	if !s.isSyncing.CompareAndSwap(false, true) {
+		return ErrSyncInProgress
+	}
+	defer s.isSyncing.Store(false)
 
-		paths := flattenTree(root, 0)
+	return fn()
+}
 
-		s.libraryRoot.Store(&paths)
+// RunSync syncs the library at `p` inside the library root `root` and
+// stores the run inside the sync history, see resolveSyncTargets. Returns
+// ErrSyncInProgress if another sync is running.
+func (s *SyncHandler) RunSync(app core.App, root, p string, options SyncOptions) error {
+	if !s.isSyncing.CompareAndSwap(false, true) {
+		return ErrSyncInProgress
+	}
 
-		s.isRetrivingPaths.Store(false)
+	// NOTE: The state is emitted after the flag is cleared so the clients
+	// waiting for the sync to finish sees isSyncing=false
+	defer func() {
+		s.isSyncing.Store(false)
 		s.EmitState()
 	}()
-}
 
-func (s *SyncHandler) Cleanup(app core.App) error {
-	tx, err := app.DB().Begin()
+	targets, err := resolveSyncTargets(app, root, p)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+
+	s.EmitState()
 
 	ctx := context.TODO()
 
-	for _, track := range s.missingTracks {
-		err := tx.DeleteTrack(ctx, track.Id)
-		if err != nil {
-			return err
-		}
+	scope := p
+	if scope == "" {
+		scope = "/"
+	}
 
-		slog.Info("Deleted track", "track", track)
+	run, err := app.DB().CreateSyncRun(ctx, database.CreateSyncRunParams{
+		Root: sql.NullString{
+			String: root,
+			Valid:  root != "",
+		},
+		Path:   scope,
+		Force:  options.Force,
+		Status: database.SyncRunStatusRunning,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create sync run: %w", err)
 	}
 
-	for _, album := range s.missingAlbums {
-		err := tx.DeleteAlbum(ctx, album.Id)
-		if err != nil {
-			return err
-		}
+	stats, syncErr := s.runSync(app, targets, p, options)
+
+	changes := database.SyncRunChanges{}
 
-		slog.Info("Deleted album", "album", album)
+	changes.Finished = types.Change[sql.NullInt64]{
+		Value: sql.NullInt64{
+			Int64: time.Now().UnixMilli(),
+			Valid: true,
+		},
+		Changed: true,
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+	var report Report
+	var reportData []byte
+
+	if syncErr == nil {
+		report = s.GetReport()
+
+		// NOTE: The run is recorded as failed if the report can't be
+		// stored, so the run isn't left as running
+		reportData, err = json.Marshal(report)
+		if err != nil {
+			syncErr = fmt.Errorf("failed to marshal sync report: %w", err)
+		}
 	}
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if syncErr != nil {
+		changes.Status = types.Change[database.SyncRunStatus]{
+			Value:   database.SyncRunStatusFailed,
+			Changed: true,
+		}
 
-	s.missingAlbums = []MissingAlbum{}
-	s.missingTracks = []MissingTrack{}
+		changes.Error = types.Change[sql.NullString]{
+			Value: sql.NullString{
+				String: syncErr.Error(),
+				Valid:  true,
+			},
+			Changed: true,
+		}
+	} else {
+		changes.Status = types.Change[database.SyncRunStatus]{
+			Value:   database.SyncRunStatusSuccess,
+			Changed: true,
+		}
 
-	s.EmitState()
+		changes.Report = types.Change[sql.NullString]{
+			Value: sql.NullString{
+				String: string(reportData),
+				Valid:  true,
+			},
+			Changed: true,
+		}
 
-	return nil
-}
+		changes.NumMissingAlbums = types.Change[int]{Value: len(report.MissingAlbums), Changed: true}
+		changes.NumMissingTracks = types.Change[int]{Value: len(report.MissingTracks), Changed: true}
+		changes.NumErrors = types.Change[int]{Value: len(report.SyncErrors), Changed: true}
+	}
 
-func (s *SyncHandler) GetStateEvent() SyncStateEvent {
-	p := s.libraryRoot.Load()
+	changes.NumCreatedAlbums = types.Change[int]{Value: stats.NumCreatedAlbums, Changed: true}
+	changes.NumUpdatedAlbums = types.Change[int]{Value: stats.NumUpdatedAlbums, Changed: true}
+	changes.NumUnchangedAlbums = types.Change[int]{Value: stats.NumUnchangedAlbums, Changed: true}
+	changes.NumCreatedTracks = types.Change[int]{Value: stats.NumCreatedTracks, Changed: true}
+	changes.NumUpdatedTracks = types.Change[int]{Value: stats.NumUpdatedTracks, Changed: true}
+	changes.NumUnchangedTracks = types.Change[int]{Value: stats.NumUnchangedTracks, Changed: true}
 
-	paths := []Path{}
-	if p != nil {
-		paths = *p
+	err = app.DB().UpdateSyncRun(ctx, run.Id, changes)
+	if err != nil {
+		slog.Error("Failed to update sync run", "id", run.Id, "err", err)
 	}
 
-	return SyncStateEvent{
-		IsSyncing:        s.isSyncing.Load(),
-		IsRetrivingPaths: s.isRetrivingPaths.Load(),
-		Paths:            paths,
-		Report: Report{
-			SyncErrors:    s.syncErrors,
-			MissingAlbums: s.missingAlbums,
-			MissingTracks: s.missingTracks,
-		},
+	summary := SyncSummaryEvent{
+		RunId:            run.Id,
+		Root:             root,
+		Path:             scope,
+		Success:          syncErr == nil,
+		Duration:         changes.Finished.Value.Int64 - run.Started,
+		SyncStats:        stats,
+		NumMissingAlbums: changes.NumMissingAlbums.Value,
+		NumMissingTracks: changes.NumMissingTracks.Value,
+		NumErrors:        changes.NumErrors.Value,
 	}
-}
 
-func (s *SyncHandler) EmitState() {
-	s.broker.EmitEvent(s.GetStateEvent())
-}
+	if syncErr != nil {
+		msg := syncErr.Error()
+		summary.Error = &msg
+	}
 
-func (s *SyncHandler) RunSync(app core.App, p string) error {
-	s.isSyncing.Store(true)
-	defer s.isSyncing.Store(false)
+	s.broker.EmitEvent(summary)
 
-	s.EmitState()
+	if options.NoBackgroundJobs {
+		return syncErr
+	}
 
-	slog.Debug("Searching for albums", "libraryDir", app.Config().LibraryDir, "path", p)
+	// NOTE: The paths contains the album status, so they needs to be
+	// updated after the sync
+	s.RetrivePaths(app)
 
-	var isRoot bool
-	if p == "" || p == "/" {
-		isRoot = true
-		p = ""
+	// NOTE: A running analysis is left alone, the new tracks is analysed
+	// by the next run
+	if syncErr == nil && app.Config().AnalyzeLoudness && !loudnessAnalyzer.isRunning.Load() {
+		startLoudnessAnalysis(app)
 	}
 
-	fullPath := path.Join(app.Config().LibraryDir, p)
+	return syncErr
+}
 
-	// TODO(patrik): Check for duplicated ids
-	search, err := library.FindAlbums(fullPath)
+func (s *SyncHandler) runSync(app core.App, targets []syncTarget, p string, options SyncOptions) (SyncStats, error) {
+	search, err := searchTargets(app, targets)
 	if err != nil {
-		return err
+		return SyncStats{}, err
 	}
 
-	slog.Debug("Done searching for albums")
+	helper := NewSyncHelper()
+
+	// NOTE: The duplicates is checked between all the searched roots
+	albums, duplicateErrors := helper.skipDuplicates(search.albums)
+
+	total := len(albums)
+
+	s.broker.EmitEvent(SyncDiscoveryEvent{
+		Path:      path.Join("/", p),
+		NumAlbums: total,
+		NumErrors: len(search.errors) + len(duplicateErrors),
+	})
 
 	ctx := context.TODO()
 
 	err = EnsureUnknownArtistExists(ctx, app.DB(), app.WorkDir())
 	if err != nil {
-		return err
+		return SyncStats{}, err
+	}
+
+	err = EnsureVariousArtistsExists(ctx, app.DB())
+	if err != nil {
+		return SyncStats{}, err
 	}
 
-	helper := SyncHelper{
-		artists: map[string]string{},
-		albums:  map[string]struct{}{},
-		tracks:  map[string]struct{}{},
+	numWorkers := app.Config().SyncWorkers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
 	}
 
 	var syncErrors []error
+	var syncErrorsMutex sync.Mutex
 
-	for _, album := range search.Albums {
-		slog.Debug("Syncing album", "path", album.Path)
-
-		err := helper.syncAlbum(ctx, &album.Metadata, app.DB())
-		if err != nil {
-			syncErrors = append(syncErrors, err)
-		}
+	type syncJob struct {
+		index int
+		album *library.Album
 	}
 
-	var missingAlbums []MissingAlbum
-	var missingTracks []MissingTrack
+	jobs := make(chan syncJob)
 
-	if isRoot {
-		ids, err := app.DB().GetAllAlbumIds(ctx)
-		if err != nil {
-			return err
-		}
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				album := job.album
+
+				s.broker.EmitEvent(SyncAlbumStartEvent{
+					Index:   job.index,
+					Total:   total,
+					AlbumId: album.Metadata.Album.Id,
+					Name:    album.Metadata.Album.Name,
+					Path:    album.Path,
+				})
 
-		for _, id := range ids {
-			_, exists := helper.albums[id]
-			if !exists {
-				album, err := app.DB().GetAlbumById(ctx, id)
+				skipped, err := helper.processAlbum(ctx, app.DB(), album, options)
 				if err != nil {
-					// TODO(patrik): How should we handle the error?
-					continue
+					syncErrorsMutex.Lock()
+					syncErrors = append(syncErrors, err)
+					syncErrorsMutex.Unlock()
+
+					s.broker.EmitEvent(SyncAlbumErrorEvent{
+						Index:   job.index,
+						Total:   total,
+						AlbumId: album.Metadata.Album.Id,
+						Path:    album.Path,
+						Message: err.Error(),
+					})
 				}
 
-				missingAlbums = append(missingAlbums, MissingAlbum{
-					Id:         id,
-					Name:       album.Name,
-					ArtistName: album.ArtistName,
+				s.broker.EmitEvent(SyncAlbumFinishEvent{
+					Index:   job.index,
+					Total:   total,
+					AlbumId: album.Metadata.Album.Id,
+					Path:    album.Path,
+					Skipped: skipped,
+					Failed:  err != nil,
 				})
 			}
-		}
+		}()
 	}
 
-	if isRoot {
-		ids, err := app.DB().GetAllTrackIds(ctx)
-		if err != nil {
-			return err
+	for i, album := range albums {
+		jobs <- syncJob{
+			index: i,
+			album: album,
 		}
+	}
 
-		for _, id := range ids {
-			_, exists := helper.tracks[id]
-			if !exists {
-				track, err := app.DB().GetTrackById(ctx, id)
-				if err != nil {
-					// TODO(patrik): How should we handle the error?
-					continue
-				}
+	close(jobs)
+	wg.Wait()
 
-				missingTracks = append(missingTracks, MissingTrack{
-					Id:         id,
-					Name:       track.Name,
-					AlbumName:  track.AlbumName,
-					ArtistName: track.ArtistName,
-				})
-			}
+	// NOTE: The artists is synced after the albums so the artist.toml
+	// overrides the artist information from the albums
+	for _, artist := range search.artists {
+		err := helper.syncArtistTx(ctx, app.DB(), app.WorkDir(), &artist)
+		if err != nil {
+			syncErrors = append(syncErrors, fmt.Errorf("%s: %w", artist.Path, err))
 		}
 	}
 
-	errs := make([]SyncError, 0, len(search.Errors)+len(syncErrors))
-
-	for _, err := range search.Errors {
-		var fullMessage *string
+	missingAlbums, missingTracks, err := helper.findMissing(ctx, app.DB(), targets)
+	if err != nil {
+		return SyncStats{}, err
+	}
 
-		var tomlError *toml.DecodeError
-		if errors.As(err, &tomlError) {
-			m := tomlError.String()
-			fullMessage = &m
+	// NOTE: Only collect the orphans after a sync of the whole root, a
+	// partial sync doesn't update the other albums
+	var orphans *CollectOrphans
+	if app.Config().CollectOrphans && targets[0].isRoot {
+		res, err := collectOrphans(ctx, app, false)
+		if err != nil {
+			syncErrors = append(syncErrors, fmt.Errorf("failed to collect orphans: %w", err))
+		} else {
+			orphans = &res
 		}
-
-		errs = append(errs, SyncError{
-			Type:        ReportTypeSearch,
-			Message:     err.Error(),
-			FullMessage: fullMessage,
-		})
 	}
 
+	errs := createSearchErrors(search.errors)
+	errs = append(errs, duplicateErrors...)
+
 	for _, err := range syncErrors {
 		errs = append(errs, SyncError{
 			Type:    ReportTypeSync,
@@ -759,11 +2019,9 @@ func (s *SyncHandler) RunSync(app core.App, p string) error {
 	s.syncErrors = errs
 	s.missingAlbums = missingAlbums
 	s.missingTracks = missingTracks
+	s.orphans = orphans
 
-	s.isSyncing.Store(false)
-	s.EmitState()
-
-	return nil
+	return helper.getStats(), nil
 }
 
 type Event struct {
@@ -775,6 +2033,8 @@ type EventData interface {
 	GetEventType() string
 }
 
+const sseClientBufferSize = 64
+
 // NOTE(patrik): Based on: https://gist.github.com/Ananto30/8af841f250e89c07e122e2a838698246
 type Broker struct {
 	Notifier chan EventData
@@ -810,12 +2070,39 @@ func (broker *Broker) listen() {
 			slog.Debug("Removed client", "numClients", len(broker.clients))
 		case event := <-broker.Notifier:
 			for clientMessageChan := range broker.clients {
-				clientMessageChan <- event
+				// NOTE: Don't let a slow client block the sync, the
+				// progress events can be sent at a high rate
+				select {
+				case clientMessageChan <- event:
+				default:
+					if isProgressEvent(event) {
+						slog.Warn("Dropping event for slow client", "type", event.GetEventType())
+						continue
+					}
+
+					// NOTE: The other events (state, summary) can't be
+					// dropped, the client is disconnected instead and
+					// gets the current state when it reconnects
+					slog.Warn("Disconnecting slow client", "type", event.GetEventType())
+					delete(broker.clients, clientMessageChan)
+					close(clientMessageChan)
+				}
 			}
 		}
 	}
 }
 
+// isProgressEvent reports if the event is a sync progress event, the
+// progress events can be dropped for slow clients
+func isProgressEvent(event EventData) bool {
+	switch event.(type) {
+	case SyncDiscoveryEvent, SyncAlbumStartEvent, SyncAlbumFinishEvent, SyncAlbumErrorEvent:
+		return true
+	}
+
+	return false
+}
+
 func (broker *Broker) EmitEvent(event EventData) {
 	broker.Notifier <- event
 }
@@ -852,35 +2139,164 @@ func (s ReportEvent) GetEventType() string {
 	return "report"
 }
 
+// SyncDiscoveryEvent is sent when the sync is done searching for albums
+type SyncDiscoveryEvent struct {
+	Path      string `json:"path"`
+	NumAlbums int    `json:"numAlbums"`
+	NumErrors int    `json:"numErrors"`
+}
+
+func (e SyncDiscoveryEvent) GetEventType() string {
+	return "sync-discovery"
+}
+
+// NOTE: The albums are synced in parallel, so the events for different
+// albums can arrive out of order
+type SyncAlbumStartEvent struct {
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	AlbumId string `json:"albumId"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+}
+
+func (e SyncAlbumStartEvent) GetEventType() string {
+	return "sync-album-start"
+}
+
+type SyncAlbumFinishEvent struct {
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	AlbumId string `json:"albumId"`
+	Path    string `json:"path"`
+	Skipped bool   `json:"skipped"`
+	Failed  bool   `json:"failed"`
+}
+
+func (e SyncAlbumFinishEvent) GetEventType() string {
+	return "sync-album-finish"
+}
+
+type SyncAlbumErrorEvent struct {
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	AlbumId string `json:"albumId"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e SyncAlbumErrorEvent) GetEventType() string {
+	return "sync-album-error"
+}
+
+// SyncSummaryEvent is sent after the sync is done and the run is stored
+type SyncSummaryEvent struct {
+	RunId    string  `json:"runId"`
+	Root     string  `json:"root,omitempty"`
+	Path     string  `json:"path"`
+	Success  bool    `json:"success"`
+	Error    *string `json:"error,omitempty"`
+	Duration int64   `json:"duration"`
+
+	SyncStats
+
+	NumMissingAlbums int `json:"numMissingAlbums"`
+	NumMissingTracks int `json:"numMissingTracks"`
+	NumErrors        int `json:"numErrors"`
+}
+
+func (e SyncSummaryEvent) GetEventType() string {
+	return "sync-summary"
+}
+
 type Path struct {
+	Root  string `json:"root"`
 	Name  string `json:"name"`
 	Path  string `json:"path"`
 	IsDir bool   `json:"isDir"`
 	Depth int    `json:"depth"`
+
+	HasAlbumFile bool    `json:"hasAlbumFile"`
+	AlbumError   *string `json:"albumError,omitempty"`
+	AlbumId      *string `json:"albumId,omitempty"`
+
+	// AlbumName is the name of the album inside the database, not set if
+	// the album isn't synced
+	AlbumName *string `json:"albumName,omitempty"`
+
+	NumTracks             int `json:"numTracks"`
+	NumUnreferencedTracks int `json:"numUnreferencedTracks"`
 }
 
 type GetLibraryPaths struct {
 	Paths []Path `json:"paths"`
 }
 
-func flattenTree(node library.FileNode, depth int) []Path {
+// setPathAlbumNames sets the name of the albums inside the database for
+// the paths with an album id
+func setPathAlbumNames(ctx context.Context, db *database.Database, paths []Path) error {
+	for i := range paths {
+		p := &paths[i]
+
+		if p.AlbumId == nil {
+			continue
+		}
+
+		album, err := db.GetAlbumById(ctx, *p.AlbumId)
+		if err != nil {
+			if errors.Is(err, database.ErrItemNotFound) {
+				continue
+			}
+
+			return err
+		}
+
+		p.AlbumName = &album.Name
+	}
+
+	return nil
+}
+
+func flattenTree(root string, node library.FileNode, depth int) []Path {
 	list := []Path{{
+		Root:  root,
 		Name:  node.Name,
 		Path:  node.Path,
 		IsDir: node.IsDir,
 		Depth: depth,
+
+		HasAlbumFile: node.HasAlbumFile,
+		AlbumError:   optionalString(node.AlbumError),
+		AlbumId:      optionalString(node.AlbumId),
+
+		NumTracks:             node.NumTracks,
+		NumUnreferencedTracks: node.NumUnreferencedTracks,
 	}}
 	for _, child := range node.Children {
-		list = append(list, flattenTree(child, depth+1)...)
+		list = append(list, flattenTree(root, child, depth+1)...)
 	}
 	return list
 }
 
 type SyncLibraryBody struct {
+	// Root is the name of the library root to sync, if not set every
+	// root is synced for the full library and the first root otherwise
+	Root string `json:"root,omitempty"`
 	Path string `json:"path,omitempty"`
+
+	// Force a full sync, including albums that haven't changed
+	Force bool `json:"force,omitempty"`
+
+	// DryRun only returns the plan of the changes, nothing is synced
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type SyncLibrary struct {
+	Plan *SyncPlan `json:"plan,omitempty"`
 }
 
 func (b *SyncLibraryBody) Transform() {
+	b.Root = anvil.String(b.Root)
 	b.Path = anvil.String(b.Path)
 }
 
@@ -933,10 +2349,24 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 					}, nil
 				}
 
-				p := syncHandler.libraryRoot.Load()
+				// NOTE: Only return the paths inside a single library
+				// root if the root query is set
+				rootName := c.Request().URL.Query().Get("root")
+
 				paths := []Path{}
-				if p != nil {
-					paths = *p
+				for _, p := range *root {
+					if rootName != "" && p.Root != rootName {
+						continue
+					}
+
+					paths = append(paths, p)
+				}
+
+				// NOTE: The album names is read when requested so the
+				// names is up to date after a sync
+				err := setPathAlbumNames(c.Request().Context(), app.DB(), paths)
+				if err != nil {
+					return nil, err
 				}
 
 				return GetLibraryPaths{
@@ -949,7 +2379,7 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 			Name:         "SyncLibrary",
 			Method:       http.MethodPost,
 			Path:         "/system/library",
-			ResponseType: nil,
+			ResponseType: SyncLibrary{},
 			BodyType:     SyncLibraryBody{},
 			HandlerFunc: func(c pyrin.Context) (any, error) {
 				// TODO(patrik):
@@ -962,23 +2392,45 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 					return nil, err
 				}
 
-				go func() {
-					if syncHandler.isSyncing.Load() {
-						slog.Info("Syncing already")
-						return
+				options := SyncOptions{
+					Force: body.Force,
+				}
+
+				if body.DryRun {
+					plan, err := syncHandler.PlanSync(app, body.Root, body.Path, options)
+					if err != nil {
+						return nil, err
+					}
+
+					return SyncLibrary{
+						Plan: &plan,
+					}, nil
+				}
+
+				if body.Root != "" {
+					_, ok := app.Config().LibraryRoot(body.Root)
+					if !ok {
+						return nil, LibraryRootNotFound()
 					}
+				}
 
+				go func() {
 					slog.Info("Started library sync")
 
-					err := syncHandler.RunSync(app, body.Path)
+					err := syncHandler.RunSync(app, body.Root, body.Path, options)
 					if err != nil {
+						if errors.Is(err, ErrSyncInProgress) {
+							slog.Info("Syncing already")
+							return
+						}
+
 						slog.Error("Failed to run sync", "err", err)
 					}
 
 					slog.Info("Library sync done")
 				}()
 
-				return nil, nil
+				return SyncLibrary{}, nil
 			},
 		},
 
@@ -999,12 +2451,12 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 			Method: http.MethodPost,
 			Path:   "/system/library/cleanup",
 			HandlerFunc: func(c pyrin.Context) (any, error) {
-				if syncHandler.isSyncing.Load() {
-					return nil, errors.New("library is syncing")
-				}
-
 				err := syncHandler.Cleanup(app)
 				if err != nil {
+					if errors.Is(err, ErrSyncInProgress) {
+						return nil, errors.New("library is syncing")
+					}
+
 					return nil, err
 				}
 
@@ -1028,7 +2480,7 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 
 				rc := http.NewResponseController(w)
 
-				eventChan := make(chan EventData)
+				eventChan := make(chan EventData, sseClientBufferSize)
 				syncHandler.broker.newClients <- eventChan
 
 				defer func() {
@@ -1055,10 +2507,15 @@ func InstallSystemHandlers(app core.App, group pyrin.Group) {
 				for {
 					select {
 					case <-r.Context().Done():
-						syncHandler.broker.closingClients <- eventChan
 						return nil
 
-					case event := <-eventChan:
+					case event, ok := <-eventChan:
+						// NOTE: Closed by the broker if the client was
+						// too slow
+						if !ok {
+							return nil
+						}
+
 						sendEvent(event)
 					}
 				}
@@ -15,13 +15,32 @@ import (
 )
 
 type Track struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	OtherName *string `json:"otherName"`
 
 	Duration int64  `json:"duration"`
 	Number   *int64 `json:"number"`
 	Year     *int64 `json:"year"`
 
+	ReleaseDate         *string `json:"releaseDate"`
+	OriginalReleaseDate *string `json:"originalReleaseDate"`
+
+	Disc         *int64  `json:"disc"`
+	DiscSubtitle *string `json:"discSubtitle"`
+
+	// BitRate is in bits per second and FileSize in bytes
+	SampleRate *int64  `json:"sampleRate"`
+	BitDepth   *int64  `json:"bitDepth"`
+	Channels   *int64  `json:"channels"`
+	BitRate    *int64  `json:"bitRate"`
+	Codec      *string `json:"codec"`
+	FileSize   *int64  `json:"fileSize"`
+
+	HasLyrics bool `json:"hasLyrics"`
+
+	ReplayGain *ReplayGain `json:"replayGain"`
+
 	CoverArt types.Images `json:"coverArt"`
 
 	AlbumId   string `json:"albumId"`
@@ -51,18 +70,31 @@ func ConvertDBTrack(c pyrin.Context, track database.Track) Track {
 	}
 
 	return Track{
-		Id:        track.Id,
-		Name:      track.Name,
-		Duration:  track.Duration,
-		Number:    ConvertSqlNullInt64(track.Number),
-		Year:      ConvertSqlNullInt64(track.Year),
-		CoverArt:  ConvertAlbumCoverURL(c, track.AlbumId, track.AlbumCoverArt),
-		AlbumId:   track.AlbumId,
-		AlbumName: track.AlbumName,
-		Artists:   artists,
-		Tags:      utils.SplitString(track.Tags.String),
-		Created:   track.Created,
-		Updated:   track.Updated,
+		Id:                  track.Id,
+		Name:                track.Name,
+		OtherName:           ConvertSqlNullString(track.OtherName),
+		Duration:            track.Duration,
+		Number:              ConvertSqlNullInt64(track.Number),
+		Year:                ConvertSqlNullInt64(track.Year),
+		ReleaseDate:         ConvertSqlNullString(track.ReleaseDate),
+		OriginalReleaseDate: ConvertSqlNullString(track.OriginalReleaseDate),
+		Disc:                ConvertSqlNullInt64(track.Disc),
+		DiscSubtitle:        ConvertSqlNullString(track.DiscSubtitle),
+		SampleRate:          ConvertSqlNullInt64(track.SampleRate),
+		BitDepth:            ConvertSqlNullInt64(track.BitDepth),
+		Channels:            ConvertSqlNullInt64(track.Channels),
+		BitRate:             ConvertSqlNullInt64(track.BitRate),
+		Codec:               ConvertSqlNullString(track.Codec),
+		FileSize:            ConvertSqlNullInt64(track.FileSize),
+		HasLyrics:           track.HasLyrics,
+		ReplayGain:          ConvertReplayGain(&track),
+		CoverArt:            ConvertAlbumCoverURL(c, track.AlbumId, track.AlbumCoverArt),
+		AlbumId:             track.AlbumId,
+		AlbumName:           track.AlbumName,
+		Artists:             artists,
+		Tags:                utils.SplitString(track.Tags.String),
+		Created:             track.Created,
+		Updated:             track.Updated,
 	}
 }
 
@@ -75,6 +107,20 @@ type GetTrackById struct {
 	Track
 }
 
+type LyricsLine struct {
+	// Time is the start of the line in seconds
+	Time float64 `json:"time"`
+	Text string  `json:"text"`
+}
+
+type GetTrackLyrics struct {
+	// Synced is set if the lines has time stamps, plain lyrics only sets
+	// the text
+	Synced bool         `json:"synced"`
+	Text   string       `json:"text"`
+	Lines  []LyricsLine `json:"lines"`
+}
+
 // TODO(patrik): Move
 func getPageOptions(q url.Values) database.FetchOptions {
 	perPage := 100
@@ -189,5 +235,46 @@ func InstallTrackHandlers(app core.App, group pyrin.Group) {
 				}, nil
 			},
 		},
+
+		pyrin.ApiHandler{
+			Name:         "GetTrackLyrics",
+			Method:       http.MethodGet,
+			Path:         "/tracks/:id/lyrics",
+			ResponseType: GetTrackLyrics{},
+			Errors:       []pyrin.ErrorType{ErrTypeTrackNotFound, ErrTypeLyricsNotFound},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				id := c.Param("id")
+
+				lyrics, err := app.DB().GetTrackLyrics(c.Request().Context(), id)
+				if err != nil {
+					if errors.Is(err, database.ErrItemNotFound) {
+						return nil, TrackNotFound()
+					}
+
+					return nil, err
+				}
+
+				if !lyrics.Valid {
+					return nil, LyricsNotFound()
+				}
+
+				parsed := utils.ParseLyrics(lyrics.String)
+
+				res := GetTrackLyrics{
+					Synced: parsed.Synced,
+					Text:   parsed.Text,
+					Lines:  make([]LyricsLine, len(parsed.Lines)),
+				}
+
+				for i, line := range parsed.Lines {
+					res.Lines[i] = LyricsLine{
+						Time: line.Time,
+						Text: line.Text,
+					}
+				}
+
+				return res, nil
+			},
+		},
 	)
 }
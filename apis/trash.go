@@ -0,0 +1,385 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/database"
+	"github.com/nanoteck137/pyrin"
+)
+
+type TrashedAlbum struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	ArtistName string `json:"artistName"`
+
+	Trashed int64 `json:"trashed"`
+}
+
+type TrashedTrack struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	AlbumId    string `json:"albumId"`
+	AlbumName  string `json:"albumName"`
+	ArtistName string `json:"artistName"`
+
+	Trashed int64 `json:"trashed"`
+}
+
+type GetTrash struct {
+	// RetentionDays is the number of days the items stays inside the
+	// trash before they are purged, 0 if the items is never purged
+	RetentionDays int `json:"retentionDays"`
+
+	Albums []TrashedAlbum `json:"albums"`
+	Tracks []TrashedTrack `json:"tracks"`
+}
+
+type PurgeTrashBody struct {
+	// All purges every item inside the trash, not only the expired ones
+	All bool `json:"all,omitempty"`
+}
+
+type PurgeTrash struct {
+	NumAlbums int `json:"numAlbums"`
+	NumTracks int `json:"numTracks"`
+}
+
+// purgeTrash deletes the tracks and albums that was moved to the trash
+// before `before`, albums is only deleted when all the tracks are gone
+func purgeTrash(ctx context.Context, db *database.Database, before int64) (PurgeTrash, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return PurgeTrash{}, err
+	}
+	defer tx.Rollback()
+
+	var res PurgeTrash
+
+	tracks, err := tx.GetTrashedTracks(ctx)
+	if err != nil {
+		return PurgeTrash{}, err
+	}
+
+	for _, track := range tracks {
+		if track.Trashed.Int64 > before {
+			continue
+		}
+
+		err := tx.DeleteTrack(ctx, track.Id)
+		if err != nil {
+			return PurgeTrash{}, err
+		}
+
+		slog.Info("Purged track", "id", track.Id, "name", track.Name)
+		res.NumTracks++
+	}
+
+	albums, err := tx.GetTrashedAlbums(ctx)
+	if err != nil {
+		return PurgeTrash{}, err
+	}
+
+	for _, album := range albums {
+		if album.Trashed.Int64 > before {
+			continue
+		}
+
+		count, err := tx.GetAlbumTrackCount(ctx, album.Id)
+		if err != nil {
+			return PurgeTrash{}, err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		err = tx.DeleteAlbum(ctx, album.Id)
+		if err != nil {
+			return PurgeTrash{}, err
+		}
+
+		slog.Info("Purged album", "id", album.Id, "name", album.Name)
+		res.NumAlbums++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return PurgeTrash{}, err
+	}
+
+	return res, nil
+}
+
+// trashCutoff returns the time the items needs to be trashed before to
+// be expired
+func trashCutoff(days int) int64 {
+	return time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+}
+
+// purgeExpiredTrash purges the items that has been inside the trash
+// longer then the retention period, does nothing if the retention is 0
+func purgeExpiredTrash(ctx context.Context, app core.App) error {
+	days := app.Config().TrashRetentionDays
+	if days <= 0 {
+		return nil
+	}
+
+	res, err := purgeTrash(ctx, app.DB(), trashCutoff(days))
+	if err != nil {
+		return err
+	}
+
+	if res.NumAlbums > 0 || res.NumTracks > 0 {
+		slog.Info("Purged expired trash", "numAlbums", res.NumAlbums, "numTracks", res.NumTracks)
+	}
+
+	return nil
+}
+
+// restoreAlbum restores the album and the tracks that was trashed together
+// with the album
+func restoreAlbum(ctx context.Context, db *database.Database, album database.Album) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.RestoreAlbum(ctx, album.Id)
+	if err != nil {
+		return err
+	}
+
+	if album.Trashed.Valid {
+		err = tx.RestoreAllTracksByAlbum(ctx, album.Id, album.Trashed.Int64)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// restoreTrack restores the track and the album of the track
+func restoreTrack(ctx context.Context, db *database.Database, track database.Track) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.RestoreTrack(ctx, track.Id)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: The track is not visible if the album is still inside the
+	// trash
+	err = tx.RestoreAlbum(ctx, track.AlbumId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func InstallTrashHandlers(app core.App, group pyrin.Group) {
+	group.Register(
+		pyrin.ApiHandler{
+			Name:         "GetTrash",
+			Method:       http.MethodGet,
+			Path:         "/system/trash",
+			ResponseType: GetTrash{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				ctx := c.Request().Context()
+
+				albums, err := app.DB().GetTrashedAlbums(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				tracks, err := app.DB().GetTrashedTracks(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				res := GetTrash{
+					RetentionDays: app.Config().TrashRetentionDays,
+					Albums:        make([]TrashedAlbum, len(albums)),
+					Tracks:        make([]TrashedTrack, len(tracks)),
+				}
+
+				for i, album := range albums {
+					res.Albums[i] = TrashedAlbum{
+						Id:         album.Id,
+						Name:       album.Name,
+						ArtistName: album.ArtistName,
+						Trashed:    album.Trashed.Int64,
+					}
+				}
+
+				for i, track := range tracks {
+					res.Tracks[i] = TrashedTrack{
+						Id:         track.Id,
+						Name:       track.Name,
+						AlbumId:    track.AlbumId,
+						AlbumName:  track.AlbumName,
+						ArtistName: track.ArtistName,
+						Trashed:    track.Trashed.Int64,
+					}
+				}
+
+				return res, nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:         "RestoreTrashedAlbum",
+			Method:       http.MethodPost,
+			Path:         "/system/trash/albums/:id/restore",
+			ResponseType: nil,
+			Errors:       []pyrin.ErrorType{ErrTypeAlbumNotFound},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				id := c.Param("id")
+
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				ctx := c.Request().Context()
+
+				album, err := app.DB().GetAlbumByIdWithTrashed(ctx, id)
+				if err != nil {
+					if errors.Is(err, database.ErrItemNotFound) {
+						return nil, AlbumNotFound()
+					}
+
+					return nil, err
+				}
+
+				// NOTE: A sync or cleanup can't run during the restore,
+				// the cleanup could trash the album again right away
+				err = syncHandler.runExclusive(func() error {
+					return restoreAlbum(ctx, app.DB(), album)
+				})
+				if err != nil {
+					if errors.Is(err, ErrSyncInProgress) {
+						return nil, errors.New("library is syncing")
+					}
+
+					return nil, err
+				}
+
+				return nil, nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:         "RestoreTrashedTrack",
+			Method:       http.MethodPost,
+			Path:         "/system/trash/tracks/:id/restore",
+			ResponseType: nil,
+			Errors:       []pyrin.ErrorType{ErrTypeTrackNotFound},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				id := c.Param("id")
+
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				ctx := c.Request().Context()
+
+				track, err := app.DB().GetTrackByIdWithTrashed(ctx, id)
+				if err != nil {
+					if errors.Is(err, database.ErrItemNotFound) {
+						return nil, TrackNotFound()
+					}
+
+					return nil, err
+				}
+
+				err = syncHandler.runExclusive(func() error {
+					return restoreTrack(ctx, app.DB(), track)
+				})
+				if err != nil {
+					if errors.Is(err, ErrSyncInProgress) {
+						return nil, errors.New("library is syncing")
+					}
+
+					return nil, err
+				}
+
+				return nil, nil
+			},
+		},
+
+		pyrin.ApiHandler{
+			Name:         "PurgeTrash",
+			Method:       http.MethodPost,
+			Path:         "/system/trash/purge",
+			ResponseType: PurgeTrash{},
+			BodyType:     PurgeTrashBody{},
+			HandlerFunc: func(c pyrin.Context) (any, error) {
+				_, err := User(app, c, RequireAdmin)
+				if err != nil {
+					return nil, err
+				}
+
+				body, err := pyrin.Body[PurgeTrashBody](c)
+				if err != nil {
+					return nil, err
+				}
+
+				before := time.Now().UnixMilli()
+				if !body.All {
+					days := app.Config().TrashRetentionDays
+					if days <= 0 {
+						return PurgeTrash{}, nil
+					}
+
+					before = trashCutoff(days)
+				}
+
+				// NOTE: A sync can't start during the purge, it could
+				// restore the albums that is being deleted
+				var res PurgeTrash
+				err = syncHandler.runExclusive(func() error {
+					var err error
+					res, err = purgeTrash(c.Request().Context(), app.DB(), before)
+					return err
+				})
+				if err != nil {
+					if errors.Is(err, ErrSyncInProgress) {
+						return nil, errors.New("library is syncing")
+					}
+
+					return nil, err
+				}
+
+				return res, nil
+			},
+		},
+	)
+}
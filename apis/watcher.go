@@ -0,0 +1,395 @@
+package apis
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/nanoteck137/dwebble/tools/utils"
+)
+
+const watcherDebounce = 2 * time.Second
+
+type LibraryChangedEvent struct {
+	Root  string   `json:"root"`
+	Paths []string `json:"paths"`
+}
+
+func (e LibraryChangedEvent) GetEventType() string {
+	return "library-changed"
+}
+
+// watchedRoot is a library root with the absolute path used to match the
+// events from the watcher
+type watchedRoot struct {
+	name string
+	path string
+}
+
+// LibraryWatcher watches the library roots and syncs the albums that
+// changed after the events have settled down
+type LibraryWatcher struct {
+	app     core.App
+	roots   []watchedRoot
+	watcher *fsnotify.Watcher
+
+	mutex      sync.Mutex
+	dirs       map[string]struct{}
+	pending    map[string]struct{}
+	pathsDirty bool
+	debounce   *time.Timer
+}
+
+func StartLibraryWatcher(app core.App) (*LibraryWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LibraryWatcher{
+		app:     app,
+		watcher: watcher,
+		dirs:    map[string]struct{}{},
+		pending: map[string]struct{}{},
+	}
+
+	for _, root := range app.Config().LibraryRoots() {
+		p, err := filepath.Abs(root.Path)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+
+		w.roots = append(w.roots, watchedRoot{
+			name: root.Name,
+			path: p,
+		})
+
+		w.addRecursive(p, false)
+
+		slog.Info("Watching library for changes", "root", root.Name, "libraryDir", p)
+	}
+
+	slog.Info("Library watcher started", "numRoots", len(w.roots), "numDirs", len(w.dirs))
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *LibraryWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// addRecursive adds watches for `dir` and all the directories inside it,
+// if `queue` is set the albums found are queued for syncing
+func (w *LibraryWatcher) addRecursive(dir string, queue bool) {
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if d == nil {
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.IsDir() {
+			if queue && (d.Name() == "album.toml" || d.Name() == "artist.toml") {
+				w.queue(path.Dir(p))
+			}
+
+			return nil
+		}
+
+		err = w.watcher.Add(p)
+		if err != nil {
+			slog.Warn("Failed to watch directory", "path", p, "err", err)
+			return nil
+		}
+
+		w.mutex.Lock()
+		w.dirs[p] = struct{}{}
+		w.mutex.Unlock()
+
+		return nil
+	})
+}
+
+func (w *LibraryWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			slog.Error("Library watcher error", "err", err)
+		}
+	}
+}
+
+func (w *LibraryWatcher) handleEvent(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	name := path.Base(event.Name)
+	if strings.HasPrefix(name, ".") {
+		return
+	}
+
+	if event.Has(fsnotify.Create) {
+		stat, err := os.Stat(event.Name)
+		if err == nil && stat.IsDir() {
+			w.addRecursive(event.Name, true)
+			w.markPathsDirty()
+			return
+		}
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.mutex.Lock()
+		_, isDir := w.dirs[event.Name]
+		if isDir {
+			for dir := range w.dirs {
+				if dir == event.Name || strings.HasPrefix(dir, event.Name+"/") {
+					delete(w.dirs, dir)
+				}
+			}
+		}
+		w.mutex.Unlock()
+
+		if isDir {
+			// NOTE: The directory is gone, flush will not find an
+			// album.toml and fall back to a full sync
+			w.queue(event.Name)
+			w.markPathsDirty()
+			return
+		}
+	}
+
+	if name == "album.toml" || name == "artist.toml" {
+		w.queue(path.Dir(event.Name))
+		return
+	}
+
+	// NOTE: The picture of an artist changed
+	if utils.IsValidImageExt(path.Ext(name)) {
+		dir := path.Dir(event.Name)
+
+		_, err := os.Stat(path.Join(dir, "artist.toml"))
+		if err == nil {
+			w.queue(dir)
+			return
+		}
+	}
+
+	// NOTE: Lyrics sidecars is part of the album fingerprint
+	if utils.IsValidTrackExt(path.Ext(name)) || utils.IsValidLyricsExt(path.Ext(name)) {
+		dir, found := w.findAlbumDir(path.Dir(event.Name))
+		if found {
+			w.queue(dir)
+		} else if w.app.Config().ImportTagged {
+			w.queue(path.Dir(event.Name))
+		}
+	}
+}
+
+// rootFor returns the library root that contains `p`, the closest root is
+// used if the roots are nested
+func (w *LibraryWatcher) rootFor(p string) (watchedRoot, bool) {
+	var res watchedRoot
+	found := false
+
+	for _, root := range w.roots {
+		if p != root.path && !strings.HasPrefix(p, root.path+"/") {
+			continue
+		}
+
+		if !found || len(root.path) > len(res.path) {
+			res = root
+			found = true
+		}
+	}
+
+	return res, found
+}
+
+// findAlbumDir searches upwards from `dir` for the closest directory
+// containing an album.toml, stopping at the library root
+func (w *LibraryWatcher) findAlbumDir(dir string) (string, bool) {
+	root, ok := w.rootFor(dir)
+	if !ok {
+		return "", false
+	}
+
+	for {
+		_, err := os.Stat(path.Join(dir, "album.toml"))
+		if err == nil {
+			return dir, true
+		}
+
+		if dir == root.path || !strings.HasPrefix(dir, root.path) {
+			return "", false
+		}
+
+		dir = path.Dir(dir)
+	}
+}
+
+func (w *LibraryWatcher) markPathsDirty() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pathsDirty = true
+	w.resetDebounce()
+}
+
+func (w *LibraryWatcher) queue(dir string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending[dir] = struct{}{}
+	w.resetDebounce()
+}
+
+// requeue adds the directories back to the pending directories, used when
+// the sync couldn't run
+func (w *LibraryWatcher) requeue(dirs []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, dir := range dirs {
+		w.pending[dir] = struct{}{}
+	}
+
+	w.resetDebounce()
+}
+
+func (w *LibraryWatcher) resetDebounce() {
+	if w.debounce != nil {
+		w.debounce.Stop()
+	}
+
+	w.debounce = time.AfterFunc(watcherDebounce, w.flush)
+}
+
+func (w *LibraryWatcher) flush() {
+	w.mutex.Lock()
+
+	// NOTE: Only a fast path, RunSync returns ErrSyncInProgress if a sync
+	// starts after this check
+	if syncHandler.isSyncing.Load() {
+		w.resetDebounce()
+		w.mutex.Unlock()
+		return
+	}
+
+	pending := w.pending
+	pathsDirty := w.pathsDirty
+
+	w.pending = map[string]struct{}{}
+	w.pathsDirty = false
+
+	w.mutex.Unlock()
+
+	if pathsDirty {
+		syncHandler.RetrivePaths(w.app)
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	// NOTE: Removed albums can only be detected by a sync from the root,
+	// so each library root is tracked separately
+	isRoot := map[string]bool{}
+	paths := map[string][]string{}
+	dirs := map[string][]string{}
+
+	for dir := range pending {
+		root, ok := w.rootFor(dir)
+		if !ok {
+			continue
+		}
+
+		dirs[root.name] = append(dirs[root.name], dir)
+
+		_, err := os.Stat(path.Join(dir, "album.toml"))
+		if err != nil {
+			_, err = os.Stat(path.Join(dir, "artist.toml"))
+		}
+
+		if err != nil {
+			// NOTE: Directories without an album.toml can still be tagged
+			// albums if the import is enabled
+			_, err := os.Stat(dir)
+			if err != nil || !w.app.Config().ImportTagged {
+				isRoot[root.name] = true
+			}
+		}
+
+		rel, err := filepath.Rel(root.path, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if rel == "." {
+			isRoot[root.name] = true
+			continue
+		}
+
+		paths[root.name] = append(paths[root.name], "/"+filepath.ToSlash(rel))
+	}
+
+	for _, root := range w.roots {
+		rootPaths := paths[root.name]
+		if isRoot[root.name] {
+			rootPaths = []string{"/"}
+		}
+
+		if len(rootPaths) == 0 {
+			continue
+		}
+
+		syncHandler.broker.EmitEvent(LibraryChangedEvent{
+			Root:  root.name,
+			Paths: rootPaths,
+		})
+
+		for _, p := range rootPaths {
+			slog.Info("Library changed, syncing", "root", root.name, "path", p)
+
+			err := syncHandler.RunSync(w.app, root.name, p, SyncOptions{})
+			if err != nil {
+				if errors.Is(err, ErrSyncInProgress) {
+					// NOTE: The whole root is queued again, the paths
+					// already synced is skipped by the fingerprints
+					slog.Info("Sync in progress, queued the changes again", "root", root.name)
+					w.requeue(dirs[root.name])
+					break
+				}
+
+				slog.Error("Failed to run sync", "root", root.name, "path", p, "err", err)
+			}
+		}
+	}
+}
@@ -5,9 +5,6 @@ import (
 	"log/slog"
 	"os"
 	"path"
-	"regexp"
-	"strconv"
-	"strings"
 
 	"github.com/nanoteck137/dwebble/library"
 	"github.com/nanoteck137/dwebble/tools/utils"
@@ -15,59 +12,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var dateRegex = regexp.MustCompile(`^([12]\d\d\d)`)
-
-func parseArtist(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-
-	splits := strings.Split(s, ",")
-
-	artists := make([]string, 0, len(splits))
-	for _, s := range splits {
-		a := strings.TrimSpace(s)
-
-		if a != "" {
-			artists = append(artists, a)
-		}
-	}
-
-	return artists
-}
-
-type TrackInfo struct {
-	Name   string
-	Artist string
-	Number int
-	Year   int
-}
-
-func getTrackInfo(p string) (TrackInfo, error) {
-	probe, err := utils.ProbeTrack(p)
-	if err != nil {
-		return TrackInfo{}, err
-	}
-
-	var res TrackInfo
-
-	res.Name, _ = probe.Tags.GetString("title")
-	res.Artist, _ = probe.Tags.GetString("artist")
-
-	if tag, err := probe.Tags.GetString("date"); err == nil {
-		match := dateRegex.FindStringSubmatch(tag)
-		if len(match) > 0 {
-			res.Year, _ = strconv.Atoi(match[1])
-		}
-	}
-
-	if tag, err := probe.Tags.GetInt("track"); err == nil {
-		res.Number = int(tag)
-	}
-
-	return res, nil
-}
-
 var initCmd = &cobra.Command{
 	Use: "init",
 	Run: func(cmd *cobra.Command, args []string) {
@@ -76,8 +20,6 @@ var initCmd = &cobra.Command{
 
 		// TODO(patrik): Features
 
-		metadata := library.Metadata{}
-
 		extract := true
 
 		// TODO(patrik): Discard hidden files (starts with .)
@@ -89,6 +31,7 @@ var initCmd = &cobra.Command{
 
 		var tracks []string
 		var images []string
+		var cues []library.CueSheet
 
 		for _, e := range entries {
 			if e.IsDir() {
@@ -106,6 +49,22 @@ var initCmd = &cobra.Command{
 			if utils.IsValidImageExt(ext) {
 				images = append(images, p)
 			}
+
+			if utils.IsValidCueExt(ext) {
+				data, err := os.ReadFile(p)
+				if err != nil {
+					slog.Error("Failed to read cue sheet", "err", err)
+					os.Exit(-1)
+				}
+
+				sheet, err := library.ParseCue(data)
+				if err != nil {
+					slog.Error("Failed to parse cue sheet", "path", p, "err", err)
+					os.Exit(-1)
+				}
+
+				cues = append(cues, sheet)
+			}
 		}
 
 		if len(tracks) <= 0 {
@@ -113,82 +72,56 @@ var initCmd = &cobra.Command{
 			return
 		}
 
-		p := tracks[0]
-
-		probe, err := utils.ProbeTrack(p)
-		if err != nil {
-			slog.Error("Failed to probe track", "err", err)
-			os.Exit(-1)
+		for i, p := range tracks {
+			tracks[i] = path.Base(p)
+			fmt.Printf("Found track: %s\n", tracks[i])
 		}
 
-		isSingle := len(tracks) == 1
-
-		metadata.Album.Id = utils.CreateAlbumId()
-
-		if len(images) > 0 {
-			// TODO(patrik): Better selection?
-			metadata.General.Cover = images[0]
+		for i, p := range images {
+			images[i] = path.Base(p)
 		}
 
-		if !isSingle {
-			metadata.Album.Name, _ = probe.Tags.GetString("album")
-		} else {
-			// NOTE(patrik): If we only have one track then we make the
-			// album name the same as the track name
-			metadata.Album.Name, _ = probe.Tags.GetString("title")
+		metadata, err := library.MetadataFromTags(dir, tracks, images)
+		if err != nil {
+			slog.Error("Failed to read tags", "err", err)
+			os.Exit(-1)
 		}
 
-		if !isSingle {
-			if tag, err := probe.Tags.GetString("album_artist"); err == nil {
-				metadata.Album.Artists = parseArtist(tag)
-			} else {
-				if tag, err := probe.Tags.GetString("artist"); err == nil {
-					metadata.Album.Artists = parseArtist(tag)
+		// NOTE: Files referenced by a cue sheet is split into the tracks
+		// from the cue sheet
+		cueTracks, used := library.CueTracks(cues, tracks)
+		if len(cueTracks) > 0 {
+			for _, t := range metadata.Tracks {
+				if _, exists := used[t.File]; !exists {
+					cueTracks = append(cueTracks, t)
 				}
 			}
-		} else {
-			if tag, err := probe.Tags.GetString("artist"); err == nil {
-				metadata.Album.Artists = parseArtist(tag)
+
+			metadata.Tracks = cueTracks
+
+			if cues[0].Title != "" {
+				metadata.Album.Name = cues[0].Title
 			}
-		}
 
-		if tag, err := probe.Tags.GetString("date"); err == nil {
-			match := dateRegex.FindStringSubmatch(tag)
-			if len(match) > 0 {
-				metadata.General.Year, _ = strconv.ParseInt(match[1], 10, 64)
+			if cues[0].Performer != "" {
+				metadata.Album.Artists = library.ParseArtists(cues[0].Performer)
 			}
 		}
 
-		for _, p := range tracks {
-			filename := path.Base(p)
-			fmt.Printf("Found track: %s\n", filename)
+		metadata.Album.Id = utils.CreateAlbumId()
+
+		if metadata.General.Cover != "" {
+			metadata.General.Cover = path.Join(dir, metadata.General.Cover)
+		}
 
-			trackInfo, err := getTrackInfo(p)
-			if err != nil {
-				slog.Error("Failed to get track info", "err", err)
-				os.Exit(-1)
-			}
+		for i := range metadata.Tracks {
+			t := &metadata.Tracks[i]
 
-			if trackInfo.Name == "" {
-				trackInfo.Name = strings.TrimSuffix(filename, path.Ext(p))
-			}
+			t.Id = utils.CreateTrackId()
 
-			if trackInfo.Number == 0 || extract {
-				trackInfo.Number = utils.ExtractNumber(filename)
+			if extract && !t.HasRange() {
+				t.Number = int64(utils.ExtractNumber(t.File))
 			}
-
-			// TODO(patrik): If artist is empty then use album maybe
-			artists := parseArtist(trackInfo.Artist)
-
-			metadata.Tracks = append(metadata.Tracks, library.MetadataTrack{
-				Id:      utils.CreateTrackId(),
-				File:    filename,
-				Name:    trackInfo.Name,
-				Number:  int64(trackInfo.Number),
-				Year:    0,
-				Tags:    []string{},
-				Artists: artists,
-			})
 		}
 
 		data, err := toml.Marshal(&metadata)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
 	"os"
 
@@ -21,6 +22,26 @@ var serveCmd = &cobra.Command{
 			os.Exit(-1)
 		}
 
+		err = app.DB().FailUnfinishedSyncRuns(context.Background())
+		if err != nil {
+			slog.Error("Failed to update unfinished sync runs", "err", err)
+			os.Exit(-1)
+		}
+
+		if app.Config().WatchLibrary {
+			_, err := apis.StartLibraryWatcher(app)
+			if err != nil {
+				slog.Error("Failed to start library watcher", "err", err)
+				os.Exit(-1)
+			}
+		}
+
+		err = apis.StartSyncScheduler(app)
+		if err != nil {
+			slog.Error("Failed to start sync scheduler", "err", err)
+			os.Exit(-1)
+		}
+
 		e, err := apis.Server(app)
 		if err != nil {
 			slog.Error("Failed to create server", "err", err)
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/nanoteck137/dwebble/apis"
+	"github.com/nanoteck137/dwebble/config"
+	"github.com/nanoteck137/dwebble/core"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:  "sync [PATH]",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		root, _ := cmd.Flags().GetString("root")
+
+		p := ""
+		if len(args) > 0 {
+			p = args[0]
+		}
+
+		app := core.NewBaseApp(&config.LoadedConfig)
+
+		err := app.Bootstrap()
+		if err != nil {
+			slog.Error("Failed to bootstrap app", "err", err)
+			os.Exit(-1)
+		}
+
+		options := apis.SyncOptions{
+			Force: force,
+		}
+
+		var res any
+
+		if dryRun {
+			res, err = apis.PlanLibrarySync(app, root, p, options)
+			if err != nil {
+				slog.Error("Failed to plan sync", "err", err)
+				os.Exit(-1)
+			}
+		} else {
+			res, err = apis.RunLibrarySync(app, root, p, options)
+			if err != nil {
+				slog.Error("Failed to run sync", "err", err)
+				os.Exit(-1)
+			}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(res)
+		if err != nil {
+			slog.Error("Failed to encode result", "err", err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func init() {
+	syncCmd.Flags().Bool("dry-run", false, "Only print the changes the sync would make")
+	syncCmd.Flags().Bool("force", false, "Sync albums even if they haven't changed")
+	syncCmd.Flags().String("root", "", "Name of the library root to sync (default every root)")
+
+	rootCmd.AddCommand(syncCmd)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 
@@ -9,6 +10,13 @@ import (
 	"github.com/spf13/viper"
 )
 
+// LibraryRoot is a named directory containing music, the name is used to
+// select the root when syncing
+type LibraryRoot struct {
+	Name string `mapstructure:"name"`
+	Path string `mapstructure:"path"`
+}
+
 type Config struct {
 	RunMigrations   bool   `mapstructure:"run_migrations"`
 	ListenAddr      string `mapstructure:"listen_addr"`
@@ -17,15 +25,66 @@ type Config struct {
 	Username        string `mapstructure:"username"`
 	InitialPassword string `mapstructure:"initial_password"`
 	JwtSecret       string `mapstructure:"jwt_secret"`
+	WatchLibrary    bool   `mapstructure:"watch_library"`
+	SyncWorkers     int    `mapstructure:"sync_workers"`
+	ImportTagged    bool   `mapstructure:"import_tagged"`
+
+	// Libraries is the named library roots, library_dir is used if empty
+	Libraries []LibraryRoot `mapstructure:"libraries"`
+
+	TrashRetentionDays int  `mapstructure:"trash_retention_days"`
+	CollectOrphans     bool `mapstructure:"collect_orphans"`
+	AnalyzeLoudness    bool `mapstructure:"analyze_loudness"`
+
+	ScheduleSync         string `mapstructure:"schedule_sync"`
+	ScheduleRefillSearch bool   `mapstructure:"schedule_refill_search"`
+	ScheduleCleanup      bool   `mapstructure:"schedule_cleanup"`
 }
 
 func (c *Config) WorkDir() types.WorkDir {
 	return types.WorkDir(c.DataDir)
 }
 
+// DefaultLibraryRootName is the name of the root created from library_dir
+const DefaultLibraryRootName = "default"
+
+// LibraryRoots returns the library roots, if no libraries is configured
+// the library_dir is used as the only root
+func (c *Config) LibraryRoots() []LibraryRoot {
+	if len(c.Libraries) > 0 {
+		return c.Libraries
+	}
+
+	return []LibraryRoot{
+		{
+			Name: DefaultLibraryRootName,
+			Path: c.LibraryDir,
+		},
+	}
+}
+
+// LibraryRoot returns the library root with `name`
+func (c *Config) LibraryRoot(name string) (LibraryRoot, bool) {
+	for _, root := range c.LibraryRoots() {
+		if root.Name == name {
+			return root, true
+		}
+	}
+
+	return LibraryRoot{}, false
+}
+
 func setDefaults() {
 	viper.SetDefault("run_migrations", "true")
 	viper.SetDefault("listen_addr", ":3000")
+	viper.SetDefault("watch_library", "false")
+	viper.SetDefault("sync_workers", "0")
+	viper.SetDefault("import_tagged", "false")
+	viper.SetDefault("trash_retention_days", "30")
+	viper.SetDefault("analyze_loudness", "false")
+	viper.SetDefault("schedule_refill_search", "false")
+	viper.SetDefault("schedule_cleanup", "false")
+	viper.BindEnv("schedule_sync")
 	viper.BindEnv("data_dir")
 	viper.BindEnv("library_dir")
 	viper.BindEnv("username")
@@ -47,11 +106,25 @@ func validateConfig(config *Config) {
 	// validate(config.RunMigrations == "", "run_migrations needs to be set")
 	validate(config.ListenAddr == "", "listen_addr needs to be set")
 	validate(config.DataDir == "", "data_dir needs to be set")
-	validate(config.LibraryDir == "", "library_dir needs to be set")
+	validate(config.LibraryDir == "" && len(config.Libraries) == 0, "library_dir or libraries needs to be set")
+
+	names := map[string]bool{}
+	for i, root := range config.Libraries {
+		validate(root.Name == "", fmt.Sprintf("libraries[%d].name needs to be set", i))
+		validate(root.Path == "", fmt.Sprintf("libraries[%d].path needs to be set", i))
+		validate(names[root.Name], fmt.Sprintf("libraries[%d].name %q is already used", i, root.Name))
+
+		names[root.Name] = true
+	}
 	validate(config.Username == "", "username needs to be set")
 	validate(config.InitialPassword == "", "initial_password needs to be set")
 	validate(config.JwtSecret == "", "jwt_secret needs to be set")
 
+	if config.ScheduleSync != "" {
+		_, err := ParseSchedule(config.ScheduleSync)
+		validate(err != nil, fmt.Sprintf("schedule_sync is not valid: %v", err))
+	}
+
 	if hasError {
 		slog.Error("Config not valid")
 		os.Exit(-1)
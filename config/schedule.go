@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseSchedule parses the interval syntax used by the scheduler, either
+// a Go duration ("6h", "90m"), "@every <duration>" or one of the aliases
+// "@hourly", "@daily" and "@weekly"
+func ParseSchedule(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	switch s {
+	case "@hourly":
+		return time.Hour, nil
+	case "@daily":
+		return 24 * time.Hour, nil
+	case "@weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+
+	s = strings.TrimSpace(strings.TrimPrefix(s, "@every"))
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule %q: %w", s, err)
+	}
+
+	if d < time.Minute {
+		return 0, fmt.Errorf("invalid schedule %q: interval needs to be at least 1m", s)
+	}
+
+	return d, nil
+}
+
+// IsAlignedSchedule reports if the schedule is one of the aliases, the
+// aliases runs at the start of the hour, day or week (UTC) instead of at a
+// fixed interval from the start of the server
+func IsAlignedSchedule(s string) bool {
+	switch strings.TrimSpace(s) {
+	case "@hourly", "@daily", "@weekly":
+		return true
+	}
+
+	return false
+}
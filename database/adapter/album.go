@@ -39,6 +39,23 @@ func (a *AlbumResolverAdapter) ResolveVariableName(name string) (filter.Name, bo
 			Name:     "albums.year",
 			Nullable: true,
 		}, true
+	case "releaseDate":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "albums.release_date",
+			Nullable: true,
+		}, true
+	case "originalReleaseDate":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "albums.original_release_date",
+			Nullable: true,
+		}, true
+	case "releaseType":
+		return filter.Name{
+			Kind: filter.NameKindString,
+			Name: "albums.release_type",
+		}, true
 	case "artistId":
 		return filter.Name{
 			Kind: filter.NameKindString,
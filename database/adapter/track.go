@@ -39,6 +39,18 @@ func (a *TrackResolverAdapter) ResolveVariableName(name string) (filter.Name, bo
 			Name:     "tracks.number",
 			Nullable: true,
 		}, true
+	case "disc":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.disc",
+			Nullable: true,
+		}, true
+	case "discSubtitle":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "tracks.disc_subtitle",
+			Nullable: true,
+		}, true
 	case "duration":
 		return filter.Name{
 			Kind:     filter.NameKindNumber,
@@ -51,6 +63,54 @@ func (a *TrackResolverAdapter) ResolveVariableName(name string) (filter.Name, bo
 			Name:     "tracks.year",
 			Nullable: true,
 		}, true
+	case "releaseDate":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "tracks.release_date",
+			Nullable: true,
+		}, true
+	case "originalReleaseDate":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "tracks.original_release_date",
+			Nullable: true,
+		}, true
+	case "sampleRate":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.sample_rate",
+			Nullable: true,
+		}, true
+	case "bitDepth":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.bit_depth",
+			Nullable: true,
+		}, true
+	case "channels":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.channels",
+			Nullable: true,
+		}, true
+	case "bitRate":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.bit_rate",
+			Nullable: true,
+		}, true
+	case "codec":
+		return filter.Name{
+			Kind:     filter.NameKindString,
+			Name:     "tracks.codec",
+			Nullable: true,
+		}, true
+	case "fileSize":
+		return filter.Name{
+			Kind:     filter.NameKindNumber,
+			Name:     "tracks.file_size",
+			Nullable: true,
+		}, true
 	case "albumId":
 		return filter.Name{
 			Kind: filter.NameKindString,
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/nanoteck137/dwebble/database/adapter"
 	"github.com/nanoteck137/dwebble/tools/filter"
 	"github.com/nanoteck137/dwebble/tools/utils"
@@ -26,6 +27,21 @@ type Album struct {
 	CoverArt sql.NullString `db:"cover_art"`
 	Year     sql.NullInt64  `db:"year"`
 
+	// ReleaseDate and OriginalReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD,
+	// the original release date is set for reissues
+	ReleaseDate         sql.NullString `db:"release_date"`
+	OriginalReleaseDate sql.NullString `db:"original_release_date"`
+
+	ReleaseType types.ReleaseType `db:"release_type"`
+
+	SyncFingerprint sql.NullString `db:"sync_fingerprint"`
+
+	Trashed sql.NullInt64 `db:"trashed"`
+
+	// LibraryRoot is the name of the library root the album was synced
+	// from, not set for albums synced before the roots was added
+	LibraryRoot sql.NullString `db:"library_root"`
+
 	ArtistName      string         `db:"artist_name"`
 	ArtistOtherName sql.NullString `db:"artist_other_name"`
 
@@ -38,7 +54,13 @@ type Album struct {
 	FeaturingArtists FeaturingArtists `db:"featuring_artists"`
 }
 
+// AlbumQuery returns the albums that is not inside the trash
 func AlbumQuery() *goqu.SelectDataset {
+	return AlbumQueryWithTrashed().
+		Where(goqu.I("albums.trashed").IsNull())
+}
+
+func AlbumQueryWithTrashed() *goqu.SelectDataset {
 	tags := dialect.From("albums_tags").
 		Select(
 			goqu.I("albums_tags.album_id").As("album_id"),
@@ -64,6 +86,17 @@ func AlbumQuery() *goqu.SelectDataset {
 			"albums.cover_art",
 			"albums.year",
 
+			"albums.release_date",
+			"albums.original_release_date",
+
+			"albums.release_type",
+
+			"albums.sync_fingerprint",
+
+			"albums.trashed",
+
+			"albums.library_root",
+
 			"albums.created",
 			"albums.updated",
 
@@ -94,7 +127,26 @@ func AlbumQuery() *goqu.SelectDataset {
 
 func (db DB) GetAllAlbumIds(ctx context.Context) ([]string, error) {
 	query := dialect.From("albums").
-		Select("albums.id")
+		Select("albums.id").
+		Where(goqu.I("albums.trashed").IsNull())
+
+	return ember.Multiple[string](db.db, ctx, query)
+}
+
+// GetAllAlbumIdsInRoot returns the albums synced from the library root,
+// `includeUnset` includes the albums without a root
+func (db DB) GetAllAlbumIdsInRoot(ctx context.Context, root string, includeUnset bool) ([]string, error) {
+	var cond exp.Expression = goqu.I("albums.library_root").Eq(root)
+	if includeUnset {
+		cond = goqu.Or(cond, goqu.I("albums.library_root").IsNull())
+	}
+
+	query := dialect.From("albums").
+		Select("albums.id").
+		Where(
+			goqu.I("albums.trashed").IsNull(),
+			cond,
+		)
 
 	return ember.Multiple[string](db.db, ctx, query)
 }
@@ -182,6 +234,14 @@ func (db DB) GetAlbumById(ctx context.Context, id string) (Album, error) {
 	return ember.Single[Album](db.db, ctx, query)
 }
 
+// GetAlbumByIdWithTrashed returns the album even if it's inside the trash
+func (db DB) GetAlbumByIdWithTrashed(ctx context.Context, id string) (Album, error) {
+	query := AlbumQueryWithTrashed().
+		Where(goqu.I("albums.id").Eq(id))
+
+	return ember.Single[Album](db.db, ctx, query)
+}
+
 func (db DB) GetAlbumByName(ctx context.Context, name string) (Album, error) {
 	query := AlbumQuery().
 		Where(goqu.I("albums.name").Eq(name))
@@ -200,6 +260,8 @@ type CreateAlbumParams struct {
 	CoverArt sql.NullString
 	Year     sql.NullInt64
 
+	ReleaseType types.ReleaseType
+
 	Created int64
 	Updated int64
 }
@@ -220,6 +282,11 @@ func (db DB) CreateAlbum(ctx context.Context, params CreateAlbumParams) (Album,
 		id = utils.CreateAlbumId()
 	}
 
+	releaseType := params.ReleaseType
+	if releaseType == "" {
+		releaseType = types.ReleaseTypeAlbum
+	}
+
 	query := dialect.Insert("albums").
 		Rows(goqu.Record{
 			"id": id,
@@ -232,6 +299,8 @@ func (db DB) CreateAlbum(ctx context.Context, params CreateAlbumParams) (Album,
 			"cover_art": params.CoverArt,
 			"year":      params.Year,
 
+			"release_type": releaseType,
+
 			"created": created,
 			"updated": updated,
 		}).
@@ -245,6 +314,8 @@ func (db DB) CreateAlbum(ctx context.Context, params CreateAlbumParams) (Album,
 
 			"albums.cover_art",
 			"albums.year",
+
+			"albums.release_type",
 		)
 
 	return ember.Single[Album](db.db, ctx, query)
@@ -271,6 +342,13 @@ type AlbumChanges struct {
 	CoverArt types.Change[sql.NullString]
 	Year     types.Change[sql.NullInt64]
 
+	ReleaseDate         types.Change[sql.NullString]
+	OriginalReleaseDate types.Change[sql.NullString]
+
+	ReleaseType types.Change[types.ReleaseType]
+
+	Trashed types.Change[sql.NullInt64]
+
 	Created types.Change[int64]
 }
 
@@ -285,6 +363,13 @@ func (db DB) UpdateAlbum(ctx context.Context, id string, changes AlbumChanges) e
 	addToRecord(record, "cover_art", changes.CoverArt)
 	addToRecord(record, "year", changes.Year)
 
+	addToRecord(record, "release_date", changes.ReleaseDate)
+	addToRecord(record, "original_release_date", changes.OriginalReleaseDate)
+
+	addToRecord(record, "release_type", changes.ReleaseType)
+
+	addToRecord(record, "trashed", changes.Trashed)
+
 	addToRecord(record, "created", changes.Created)
 
 	if len(record) == 0 {
@@ -305,6 +390,38 @@ func (db DB) UpdateAlbum(ctx context.Context, id string, changes AlbumChanges) e
 	return nil
 }
 
+// UpdateAlbumSyncFingerprint sets the fingerprint used by the library
+// sync to detect unchanged albums, this doesn't touch the updated time
+func (db DB) UpdateAlbumSyncFingerprint(ctx context.Context, id string, fingerprint sql.NullString) error {
+	ds := dialect.Update("albums").
+		Set(goqu.Record{
+			"sync_fingerprint": fingerprint,
+		}).
+		Where(goqu.I("albums.id").Eq(id))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db DB) UpdateAlbumLibraryRoot(ctx context.Context, id string, root sql.NullString) error {
+	ds := dialect.Update("albums").
+		Set(goqu.Record{
+			"library_root": root,
+		}).
+		Where(goqu.I("albums.id").Eq(id))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (db DB) ChangeAllAlbumArtist(ctx context.Context, artistId, newArtistId string) error {
 	query := goqu.Update("albums").
 		Set(goqu.Record{
@@ -65,7 +65,7 @@ func (db *Database) Begin() (Tx, error) {
 
 func Open(dbFile string) (*Database, error) {
 	// dbUrl := fmt.Sprintf("file:%s?_foreign_keys=true", dbFile)
-	dbUrl := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=ON&_serialized=1&_synchronous=NORMAL", dbFile)
+	dbUrl := fmt.Sprintf("file:%s?_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL&_foreign_keys=ON&_serialized=1&_synchronous=NORMAL", dbFile)
 	db, err := ember.OpenDatabase("sqlite3", dbUrl)
 	if err != nil {
 		return nil, err
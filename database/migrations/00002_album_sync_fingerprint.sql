@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE albums ADD COLUMN sync_fingerprint TEXT;
+
+-- +goose Down
+ALTER TABLE albums DROP COLUMN sync_fingerprint;
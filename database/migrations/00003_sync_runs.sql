@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE sync_runs (
+    id TEXT PRIMARY KEY,
+
+    path TEXT NOT NULL,
+    force BOOLEAN NOT NULL,
+
+    status TEXT NOT NULL,
+    error TEXT,
+
+    started INTEGER NOT NULL,
+    finished INTEGER,
+
+    num_created_albums INTEGER NOT NULL DEFAULT 0,
+    num_updated_albums INTEGER NOT NULL DEFAULT 0,
+    num_unchanged_albums INTEGER NOT NULL DEFAULT 0,
+
+    num_created_tracks INTEGER NOT NULL DEFAULT 0,
+    num_updated_tracks INTEGER NOT NULL DEFAULT 0,
+    num_unchanged_tracks INTEGER NOT NULL DEFAULT 0,
+
+    num_missing_albums INTEGER NOT NULL DEFAULT 0,
+    num_missing_tracks INTEGER NOT NULL DEFAULT 0,
+    num_errors INTEGER NOT NULL DEFAULT 0,
+
+    report TEXT,
+
+    created INTEGER NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE INDEX sync_runs_started_idx ON sync_runs(started);
+
+-- +goose Down
+DROP INDEX sync_runs_started_idx;
+DROP TABLE sync_runs;
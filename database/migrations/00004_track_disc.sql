@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN disc INTEGER;
+ALTER TABLE tracks ADD COLUMN disc_subtitle TEXT;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN disc_subtitle;
+ALTER TABLE tracks DROP COLUMN disc;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE albums ADD COLUMN trashed INTEGER;
+ALTER TABLE tracks ADD COLUMN trashed INTEGER;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN trashed;
+ALTER TABLE albums DROP COLUMN trashed;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE albums ADD COLUMN library_root TEXT;
+ALTER TABLE sync_runs ADD COLUMN root TEXT;
+
+-- +goose Down
+ALTER TABLE sync_runs DROP COLUMN root;
+ALTER TABLE albums DROP COLUMN library_root;
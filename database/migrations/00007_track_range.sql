@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN range_start INTEGER;
+ALTER TABLE tracks ADD COLUMN range_end INTEGER;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN range_end;
+ALTER TABLE tracks DROP COLUMN range_start;
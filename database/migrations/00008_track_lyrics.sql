@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN lyrics TEXT;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN lyrics;
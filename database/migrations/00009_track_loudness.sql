@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN track_gain REAL;
+ALTER TABLE tracks ADD COLUMN track_peak REAL;
+ALTER TABLE tracks ADD COLUMN album_gain REAL;
+ALTER TABLE tracks ADD COLUMN album_peak REAL;
+ALTER TABLE tracks ADD COLUMN loudness_analyzed INTEGER;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN loudness_analyzed;
+ALTER TABLE tracks DROP COLUMN album_peak;
+ALTER TABLE tracks DROP COLUMN album_gain;
+ALTER TABLE tracks DROP COLUMN track_peak;
+ALTER TABLE tracks DROP COLUMN track_gain;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN content_hash TEXT;
+
+CREATE INDEX tracks_content_hash_idx ON tracks(content_hash);
+
+-- NOTE: Clear the fingerprints so the next sync hashes the existing tracks
+UPDATE albums SET sync_fingerprint = NULL;
+
+-- +goose Down
+DROP INDEX tracks_content_hash_idx;
+
+ALTER TABLE tracks DROP COLUMN content_hash;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE tracks ADD COLUMN sample_rate INTEGER;
+ALTER TABLE tracks ADD COLUMN bit_depth INTEGER;
+ALTER TABLE tracks ADD COLUMN channels INTEGER;
+ALTER TABLE tracks ADD COLUMN bit_rate INTEGER;
+ALTER TABLE tracks ADD COLUMN codec TEXT;
+ALTER TABLE tracks ADD COLUMN file_size INTEGER;
+
+-- NOTE: Clear the fingerprints so the next sync probes the existing tracks
+UPDATE albums SET sync_fingerprint = NULL;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN file_size;
+ALTER TABLE tracks DROP COLUMN codec;
+ALTER TABLE tracks DROP COLUMN bit_rate;
+ALTER TABLE tracks DROP COLUMN channels;
+ALTER TABLE tracks DROP COLUMN bit_depth;
+ALTER TABLE tracks DROP COLUMN sample_rate;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE albums ADD COLUMN release_type TEXT NOT NULL DEFAULT 'album';
+
+-- NOTE: Clear the fingerprints so the next sync reads the release types
+UPDATE albums SET sync_fingerprint = NULL;
+
+-- +goose Down
+ALTER TABLE albums DROP COLUMN release_type;
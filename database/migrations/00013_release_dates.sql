@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE albums ADD COLUMN release_date TEXT;
+ALTER TABLE albums ADD COLUMN original_release_date TEXT;
+
+ALTER TABLE tracks ADD COLUMN release_date TEXT;
+ALTER TABLE tracks ADD COLUMN original_release_date TEXT;
+
+-- NOTE: The existing years is used as the release dates until the albums
+-- is synced again, the fingerprints is cleared below
+UPDATE albums SET release_date = printf('%04d', year) WHERE year IS NOT NULL AND year > 0;
+UPDATE tracks SET release_date = printf('%04d', year) WHERE year IS NOT NULL AND year > 0;
+
+-- NOTE: Clear the fingerprints so the next sync reads the full dates
+UPDATE albums SET sync_fingerprint = NULL;
+
+-- +goose Down
+ALTER TABLE tracks DROP COLUMN original_release_date;
+ALTER TABLE tracks DROP COLUMN release_date;
+
+ALTER TABLE albums DROP COLUMN original_release_date;
+ALTER TABLE albums DROP COLUMN release_date;
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/nanoteck137/pyrin/ember"
+)
+
+// GetOrphanedArtists returns the artists that isn't used by any album or
+// track, the albums and tracks inside the trash still counts as used
+func (db DB) GetOrphanedArtists(ctx context.Context) ([]Artist, error) {
+	query := ArtistQuery().
+		Where(
+			goqu.I("artists.id").NotIn(
+				dialect.From("albums").Select("albums.artist_id"),
+			),
+			goqu.I("artists.id").NotIn(
+				dialect.From("tracks").Select("tracks.artist_id"),
+			),
+			goqu.I("artists.id").NotIn(
+				dialect.From("albums_featuring_artists").
+					Select("albums_featuring_artists.artist_id"),
+			),
+			goqu.I("artists.id").NotIn(
+				dialect.From("tracks_featuring_artists").
+					Select("tracks_featuring_artists.artist_id"),
+			),
+		)
+
+	return ember.Multiple[Artist](db.db, ctx, query)
+}
+
+// GetOrphanedTags returns the tags that isn't used by any artist, album or
+// track
+func (db DB) GetOrphanedTags(ctx context.Context) ([]Tag, error) {
+	// NOTE: The tag_slug columns is nullable, NOT IN never matches if the
+	// sub query contains a NULL
+	query := TagQuery().
+		Where(
+			goqu.I("tags.slug").NotIn(
+				dialect.From("artists_tags").
+					Select("artists_tags.tag_slug").
+					Where(goqu.I("artists_tags.tag_slug").IsNotNull()),
+			),
+			goqu.I("tags.slug").NotIn(
+				dialect.From("albums_tags").
+					Select("albums_tags.tag_slug").
+					Where(goqu.I("albums_tags.tag_slug").IsNotNull()),
+			),
+			goqu.I("tags.slug").NotIn(
+				dialect.From("tracks_tags").
+					Select("tracks_tags.tag_slug").
+					Where(goqu.I("tracks_tags.tag_slug").IsNotNull()),
+			),
+		)
+
+	return ember.Multiple[Tag](db.db, ctx, query)
+}
+
+func (db DB) DeleteTag(ctx context.Context, slug string) error {
+	query := dialect.Delete("tags").
+		Where(goqu.I("tags.slug").Eq(slug))
+
+	_, err := db.db.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -427,6 +427,10 @@ func NewTrackQuery() *goqu.SelectDataset {
 		LeftJoin(
 			trackMediaQuery.As("tracks_media"),
 			goqu.On(goqu.I("tracks.id").Eq(goqu.I("tracks_media.id"))),
+		).
+		Where(
+			goqu.I("tracks.trashed").IsNull(),
+			goqu.I("albums.trashed").IsNull(),
 		)
 
 		// LeftJoin(
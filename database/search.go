@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/nanoteck137/dwebble/tools/utils"
 	"github.com/nanoteck137/pyrin/ember"
 )
 
@@ -238,6 +239,8 @@ func (db DB) InitializeSearch() error {
 
 				tags, 
 
+				lyrics,
+
 				tokenize=trigram
 			);
 		`,
@@ -362,7 +365,27 @@ func (db DB) UpdateSearchAlbum(ctx context.Context, data Album) error {
 	return nil
 }
 
+// trackSearchLyrics returns the lyrics text to index for the track, the
+// time stamps of synced lyrics is removed
+func (db DB) trackSearchLyrics(ctx context.Context, data Track) (string, error) {
+	if !data.HasLyrics {
+		return "", nil
+	}
+
+	lyrics, err := db.GetTrackLyrics(ctx, data.Id)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.ParseLyrics(lyrics.String).Text, nil
+}
+
 func (db DB) InsertTrackToSearch(ctx context.Context, data Track) error {
+	lyrics, err := db.trackSearchLyrics(ctx, data)
+	if err != nil {
+		return err
+	}
+
 	query := dialect.Insert("tracks_search").Rows(goqu.Record{
 		"rowid": data.RowId,
 
@@ -378,9 +401,11 @@ func (db DB) InsertTrackToSearch(ctx context.Context, data Track) error {
 		"album_other_name": data.AlbumOtherName,
 
 		"tags": data.Tags.String,
+
+		"lyrics": lyrics,
 	})
 
-	_, err := db.db.Exec(ctx, query)
+	_, err = db.db.Exec(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -404,6 +429,11 @@ func (db DB) DeleteTrackFromSearch(ctx context.Context, data Track) error {
 func (db DB) UpdateSearchTrack(ctx context.Context, data Track) error {
 	slog.Debug("Updating track search", "track", data)
 
+	lyrics, err := db.trackSearchLyrics(ctx, data)
+	if err != nil {
+		return err
+	}
+
 	query := dialect.Update("tracks_search").
 		Set(goqu.Record{
 			"id": data.Id,
@@ -418,10 +448,12 @@ func (db DB) UpdateSearchTrack(ctx context.Context, data Track) error {
 			"album_other_name": data.AlbumOtherName,
 
 			"tags": data.Tags.String,
+
+			"lyrics": lyrics,
 		}).
 		Where(goqu.I("tracks_search.rowid").Eq(data.RowId))
 
-	_, err := db.db.Exec(ctx, query)
+	_, err = db.db.Exec(ctx, query)
 	if err != nil {
 		return err
 	}
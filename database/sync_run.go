@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+	"github.com/nanoteck137/pyrin/ember"
+)
+
+type SyncRunStatus string
+
+const (
+	SyncRunStatusRunning SyncRunStatus = "running"
+	SyncRunStatusSuccess SyncRunStatus = "success"
+	SyncRunStatusFailed  SyncRunStatus = "failed"
+)
+
+type SyncRun struct {
+	Id string `db:"id"`
+
+	// NOTE: Root is not set for all the roots
+	Root  sql.NullString `db:"root"`
+	Path  string         `db:"path"`
+	Force bool           `db:"force"`
+
+	Status SyncRunStatus  `db:"status"`
+	Error  sql.NullString `db:"error"`
+
+	Started  int64         `db:"started"`
+	Finished sql.NullInt64 `db:"finished"`
+
+	NumCreatedAlbums   int `db:"num_created_albums"`
+	NumUpdatedAlbums   int `db:"num_updated_albums"`
+	NumUnchangedAlbums int `db:"num_unchanged_albums"`
+
+	NumCreatedTracks   int `db:"num_created_tracks"`
+	NumUpdatedTracks   int `db:"num_updated_tracks"`
+	NumUnchangedTracks int `db:"num_unchanged_tracks"`
+
+	NumMissingAlbums int `db:"num_missing_albums"`
+	NumMissingTracks int `db:"num_missing_tracks"`
+	NumErrors        int `db:"num_errors"`
+
+	// NOTE: JSON encoded report from the sync
+	Report sql.NullString `db:"report"`
+
+	Created int64 `db:"created"`
+	Updated int64 `db:"updated"`
+}
+
+func SyncRunQuery() *goqu.SelectDataset {
+	query := dialect.From("sync_runs").
+		Select(
+			"sync_runs.id",
+
+			"sync_runs.root",
+			"sync_runs.path",
+			"sync_runs.force",
+
+			"sync_runs.status",
+			"sync_runs.error",
+
+			"sync_runs.started",
+			"sync_runs.finished",
+
+			"sync_runs.num_created_albums",
+			"sync_runs.num_updated_albums",
+			"sync_runs.num_unchanged_albums",
+
+			"sync_runs.num_created_tracks",
+			"sync_runs.num_updated_tracks",
+			"sync_runs.num_unchanged_tracks",
+
+			"sync_runs.num_missing_albums",
+			"sync_runs.num_missing_tracks",
+			"sync_runs.num_errors",
+
+			"sync_runs.report",
+
+			"sync_runs.created",
+			"sync_runs.updated",
+		).
+		Prepared(true)
+
+	return query
+}
+
+func (db DB) GetSyncRunsPaged(ctx context.Context, opts FetchOptions) ([]SyncRun, types.Page, error) {
+	query := SyncRunQuery().
+		Order(goqu.I("sync_runs.started").Desc())
+
+	countQuery := query.
+		Select(goqu.COUNT("sync_runs.id"))
+
+	if opts.PerPage > 0 {
+		query = query.
+			Limit(uint(opts.PerPage)).
+			Offset(uint(opts.Page * opts.PerPage))
+	}
+
+	totalItems, err := ember.Single[int](db.db, ctx, countQuery)
+	if err != nil {
+		return nil, types.Page{}, err
+	}
+
+	totalPages := utils.TotalPages(opts.PerPage, totalItems)
+	page := types.Page{
+		Page:       opts.Page,
+		PerPage:    opts.PerPage,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	items, err := ember.Multiple[SyncRun](db.db, ctx, query)
+	if err != nil {
+		return nil, types.Page{}, err
+	}
+
+	return items, page, nil
+}
+
+func (db DB) GetSyncRunById(ctx context.Context, id string) (SyncRun, error) {
+	query := SyncRunQuery().
+		Where(goqu.I("sync_runs.id").Eq(id))
+
+	return ember.Single[SyncRun](db.db, ctx, query)
+}
+
+type CreateSyncRunParams struct {
+	Id string
+
+	Root  sql.NullString
+	Path  string
+	Force bool
+
+	Status  SyncRunStatus
+	Started int64
+
+	Created int64
+	Updated int64
+}
+
+func (db DB) CreateSyncRun(ctx context.Context, params CreateSyncRunParams) (SyncRun, error) {
+	t := time.Now().UnixMilli()
+	created := params.Created
+	updated := params.Updated
+
+	if created == 0 && updated == 0 {
+		created = t
+		updated = t
+	}
+
+	id := params.Id
+	if id == "" {
+		id = utils.CreateSyncRunId()
+	}
+
+	started := params.Started
+	if started == 0 {
+		started = t
+	}
+
+	query := dialect.Insert("sync_runs").
+		Rows(goqu.Record{
+			"id": id,
+
+			"root":  params.Root,
+			"path":  params.Path,
+			"force": params.Force,
+
+			"status":  params.Status,
+			"started": started,
+
+			"created": created,
+			"updated": updated,
+		}).
+		Returning("id")
+
+	id, err := ember.Single[string](db.db, ctx, query)
+	if err != nil {
+		return SyncRun{}, err
+	}
+
+	return db.GetSyncRunById(ctx, id)
+}
+
+type SyncRunChanges struct {
+	Status types.Change[SyncRunStatus]
+	Error  types.Change[sql.NullString]
+
+	Finished types.Change[sql.NullInt64]
+
+	NumCreatedAlbums   types.Change[int]
+	NumUpdatedAlbums   types.Change[int]
+	NumUnchangedAlbums types.Change[int]
+
+	NumCreatedTracks   types.Change[int]
+	NumUpdatedTracks   types.Change[int]
+	NumUnchangedTracks types.Change[int]
+
+	NumMissingAlbums types.Change[int]
+	NumMissingTracks types.Change[int]
+	NumErrors        types.Change[int]
+
+	Report types.Change[sql.NullString]
+}
+
+func (db DB) UpdateSyncRun(ctx context.Context, id string, changes SyncRunChanges) error {
+	record := goqu.Record{}
+
+	addToRecord(record, "status", changes.Status)
+	addToRecord(record, "error", changes.Error)
+
+	addToRecord(record, "finished", changes.Finished)
+
+	addToRecord(record, "num_created_albums", changes.NumCreatedAlbums)
+	addToRecord(record, "num_updated_albums", changes.NumUpdatedAlbums)
+	addToRecord(record, "num_unchanged_albums", changes.NumUnchangedAlbums)
+
+	addToRecord(record, "num_created_tracks", changes.NumCreatedTracks)
+	addToRecord(record, "num_updated_tracks", changes.NumUpdatedTracks)
+	addToRecord(record, "num_unchanged_tracks", changes.NumUnchangedTracks)
+
+	addToRecord(record, "num_missing_albums", changes.NumMissingAlbums)
+	addToRecord(record, "num_missing_tracks", changes.NumMissingTracks)
+	addToRecord(record, "num_errors", changes.NumErrors)
+
+	addToRecord(record, "report", changes.Report)
+
+	if len(record) == 0 {
+		return nil
+	}
+
+	record["updated"] = time.Now().UnixMilli()
+
+	ds := dialect.Update("sync_runs").
+		Set(record).
+		Where(goqu.I("sync_runs.id").Eq(id))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FailUnfinishedSyncRuns marks the runs that never finished as failed,
+// used on startup because a run can't survive a restart
+func (db DB) FailUnfinishedSyncRuns(ctx context.Context) error {
+	ds := dialect.Update("sync_runs").
+		Set(goqu.Record{
+			"status":  SyncRunStatusFailed,
+			"error":   "interrupted",
+			"updated": time.Now().UnixMilli(),
+		}).
+		Where(goqu.I("sync_runs.status").Eq(SyncRunStatusRunning))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
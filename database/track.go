@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/nanoteck137/dwebble/database/adapter"
 	"github.com/nanoteck137/dwebble/tools/filter"
 	"github.com/nanoteck137/dwebble/tools/utils"
@@ -32,6 +33,43 @@ type Track struct {
 	Number   sql.NullInt64 `db:"number"`
 	Year     sql.NullInt64 `db:"year"`
 
+	// ReleaseDate and OriginalReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD
+	ReleaseDate         sql.NullString `db:"release_date"`
+	OriginalReleaseDate sql.NullString `db:"original_release_date"`
+
+	// Technical properties of the audio, not set if unknown. The bit rate
+	// is in bits per second and the file size in bytes.
+	SampleRate sql.NullInt64  `db:"sample_rate"`
+	BitDepth   sql.NullInt64  `db:"bit_depth"`
+	Channels   sql.NullInt64  `db:"channels"`
+	BitRate    sql.NullInt64  `db:"bit_rate"`
+	Codec      sql.NullString `db:"codec"`
+	FileSize   sql.NullInt64  `db:"file_size"`
+
+	Disc         sql.NullInt64  `db:"disc"`
+	DiscSubtitle sql.NullString `db:"disc_subtitle"`
+
+	// RangeStart and RangeEnd is the range of the track inside the file
+	// in milliseconds, not set if the track uses the whole file
+	RangeStart sql.NullInt64 `db:"range_start"`
+	RangeEnd   sql.NullInt64 `db:"range_end"`
+
+	// NOTE: The lyrics is only fetched with GetTrackLyrics
+	HasLyrics bool `db:"has_lyrics"`
+
+	// ReplayGain values, the gain is in dB and the peak is linear.
+	// LoudnessAnalyzed is not set if the track needs to be analysed.
+	TrackGain        sql.NullFloat64 `db:"track_gain"`
+	TrackPeak        sql.NullFloat64 `db:"track_peak"`
+	AlbumGain        sql.NullFloat64 `db:"album_gain"`
+	AlbumPeak        sql.NullFloat64 `db:"album_peak"`
+	LoudnessAnalyzed sql.NullInt64   `db:"loudness_analyzed"`
+
+	// ContentHash is the hash of the audio, used to find duplicates
+	ContentHash sql.NullString `db:"content_hash"`
+
+	Trashed sql.NullInt64 `db:"trashed"`
+
 	OriginalFilename string `db:"original_filename"`
 	MobileFilename   string `db:"mobile_filename"`
 
@@ -78,7 +116,17 @@ func FeaturingArtistsQuery(table, idColName string) *goqu.SelectDataset {
 }
 
 // TODO(patrik): Use goqu.T more
+// TrackQuery returns the tracks that is not inside the trash, tracks from
+// albums inside the trash is also excluded
 func TrackQuery() *goqu.SelectDataset {
+	return TrackQueryWithTrashed().
+		Where(
+			goqu.I("tracks.trashed").IsNull(),
+			goqu.I("albums.trashed").IsNull(),
+		)
+}
+
+func TrackQueryWithTrashed() *goqu.SelectDataset {
 	tags := dialect.From("tracks_tags").
 		Select(
 			goqu.I("tracks_tags.track_id").As("track_id"),
@@ -110,6 +158,34 @@ func TrackQuery() *goqu.SelectDataset {
 			"tracks.duration",
 			"tracks.year",
 
+			"tracks.release_date",
+			"tracks.original_release_date",
+
+			"tracks.sample_rate",
+			"tracks.bit_depth",
+			"tracks.channels",
+			"tracks.bit_rate",
+			"tracks.codec",
+			"tracks.file_size",
+
+			"tracks.disc",
+			"tracks.disc_subtitle",
+
+			"tracks.range_start",
+			"tracks.range_end",
+
+			goqu.L("tracks.lyrics IS NOT NULL").As("has_lyrics"),
+
+			"tracks.track_gain",
+			"tracks.track_peak",
+			"tracks.album_gain",
+			"tracks.album_peak",
+			"tracks.loudness_analyzed",
+
+			"tracks.content_hash",
+
+			"tracks.trashed",
+
 			"tracks.created",
 			"tracks.updated",
 
@@ -157,10 +233,20 @@ func (db DB) GetTracksByIds(ctx context.Context, ids []string) ([]Track, error)
 	return ember.Multiple[Track](db.db, ctx, query)
 }
 
+// albumTrackOrder is the order of the tracks inside an album, tracks
+// without a disc is on disc 1
+func albumTrackOrder() []exp.OrderedExpression {
+	return []exp.OrderedExpression{
+		goqu.COALESCE(goqu.I("tracks.disc"), 1).Asc(),
+		goqu.I("tracks.number").Asc().NullsLast(),
+		goqu.I("tracks.name").Asc(),
+	}
+}
+
 func (db DB) GetTracksByAlbumForPlay(ctx context.Context, albumId string) ([]NewTrackQueryItem, error) {
 	query := NewTrackQuery().
 		Where(goqu.I("tracks.album_id").Eq(albumId)).
-		Order(goqu.I("tracks.number").Asc().NullsLast(), goqu.I("tracks.name").Asc()).
+		Order(albumTrackOrder()...).
 		As("tracks")
 
 	return ember.Multiple[NewTrackQueryItem](db.db, ctx, query)
@@ -168,7 +254,30 @@ func (db DB) GetTracksByAlbumForPlay(ctx context.Context, albumId string) ([]New
 
 func (db DB) GetAllTrackIds(ctx context.Context) ([]string, error) {
 	query := dialect.From("tracks").
-		Select("tracks.id")
+		Select("tracks.id").
+		Where(goqu.I("tracks.trashed").IsNull())
+
+	return ember.Multiple[string](db.db, ctx, query)
+}
+
+// GetAllTrackIdsInRoot returns the tracks from the albums synced from the
+// library root, `includeUnset` includes the albums without a root
+func (db DB) GetAllTrackIdsInRoot(ctx context.Context, root string, includeUnset bool) ([]string, error) {
+	var cond exp.Expression = goqu.I("albums.library_root").Eq(root)
+	if includeUnset {
+		cond = goqu.Or(cond, goqu.I("albums.library_root").IsNull())
+	}
+
+	query := dialect.From("tracks").
+		Select("tracks.id").
+		Join(
+			goqu.I("albums"),
+			goqu.On(goqu.I("tracks.album_id").Eq(goqu.I("albums.id"))),
+		).
+		Where(
+			goqu.I("tracks.trashed").IsNull(),
+			cond,
+		)
 
 	return ember.Multiple[string](db.db, ctx, query)
 }
@@ -259,10 +368,7 @@ func (db DB) GetTracksByAlbum(ctx context.Context, albumId string) ([]Track, err
 					Where(goqu.I("tracks.album_id").Eq(albumId)),
 			),
 		).
-		Order(
-			goqu.I("tracks.number").Asc().NullsLast(),
-			goqu.I("tracks.name").Asc(),
-		)
+		Order(albumTrackOrder()...)
 
 	return ember.Multiple[Track](db.db, ctx, query)
 }
@@ -323,6 +429,14 @@ func (db DB) GetTrackById(ctx context.Context, id string) (Track, error) {
 	return ember.Single[Track](db.db, ctx, query)
 }
 
+// GetTrackByIdWithTrashed returns the track even if it's inside the trash
+func (db DB) GetTrackByIdWithTrashed(ctx context.Context, id string) (Track, error) {
+	query := TrackQueryWithTrashed().
+		Where(goqu.I("tracks.id").Eq(id))
+
+	return ember.Single[Track](db.db, ctx, query)
+}
+
 func (db DB) GetTrackByNameAndAlbum(ctx context.Context, name string, albumId string) (Track, error) {
 	query := TrackQuery().
 		Where(
@@ -335,6 +449,34 @@ func (db DB) GetTrackByNameAndAlbum(ctx context.Context, name string, albumId st
 	return ember.Single[Track](db.db, ctx, query)
 }
 
+// GetAlbumIdsWithUnanalyzedTracks returns the albums that has tracks
+// without a loudness analysis, the tracks inside the trash is skipped
+func (db DB) GetAlbumIdsWithUnanalyzedTracks(ctx context.Context) ([]string, error) {
+	query := dialect.From("tracks").
+		Select("tracks.album_id").
+		Distinct().
+		Join(
+			goqu.I("albums"),
+			goqu.On(goqu.I("tracks.album_id").Eq(goqu.I("albums.id"))),
+		).
+		Where(
+			goqu.I("tracks.loudness_analyzed").IsNull(),
+			goqu.I("tracks.trashed").IsNull(),
+			goqu.I("albums.trashed").IsNull(),
+		)
+
+	return ember.Multiple[string](db.db, ctx, query)
+}
+
+// GetTrackLyrics returns the raw lyrics (plain or LRC) of the track
+func (db DB) GetTrackLyrics(ctx context.Context, id string) (sql.NullString, error) {
+	query := dialect.From("tracks").
+		Select("tracks.lyrics").
+		Where(goqu.I("tracks.id").Eq(id))
+
+	return ember.Single[sql.NullString](db.db, ctx, query)
+}
+
 type CreateTrackParams struct {
 	Id   string
 
@@ -352,6 +494,26 @@ type CreateTrackParams struct {
 	Number   sql.NullInt64
 	Year     sql.NullInt64
 
+	ReleaseDate         sql.NullString
+	OriginalReleaseDate sql.NullString
+
+	SampleRate sql.NullInt64
+	BitDepth   sql.NullInt64
+	Channels   sql.NullInt64
+	BitRate    sql.NullInt64
+	Codec      sql.NullString
+	FileSize   sql.NullInt64
+
+	Disc         sql.NullInt64
+	DiscSubtitle sql.NullString
+
+	RangeStart sql.NullInt64
+	RangeEnd   sql.NullInt64
+
+	Lyrics sql.NullString
+
+	ContentHash sql.NullString
+
 	// OriginalFilename string
 	// MobileFilename   string
 
@@ -391,6 +553,26 @@ func (db DB) CreateTrack(ctx context.Context, params CreateTrackParams) (string,
 		"number":   params.Number,
 		"year":     params.Year,
 
+		"release_date":          params.ReleaseDate,
+		"original_release_date": params.OriginalReleaseDate,
+
+		"sample_rate": params.SampleRate,
+		"bit_depth":   params.BitDepth,
+		"channels":    params.Channels,
+		"bit_rate":    params.BitRate,
+		"codec":       params.Codec,
+		"file_size":   params.FileSize,
+
+		"disc":          params.Disc,
+		"disc_subtitle": params.DiscSubtitle,
+
+		"range_start": params.RangeStart,
+		"range_end":   params.RangeEnd,
+
+		"lyrics": params.Lyrics,
+
+		"content_hash": params.ContentHash,
+
 		"created": created,
 		"updated": updated,
 	}).
@@ -414,6 +596,34 @@ type TrackChanges struct {
 	Number   types.Change[sql.NullInt64]
 	Year     types.Change[sql.NullInt64]
 
+	ReleaseDate         types.Change[sql.NullString]
+	OriginalReleaseDate types.Change[sql.NullString]
+
+	SampleRate types.Change[sql.NullInt64]
+	BitDepth   types.Change[sql.NullInt64]
+	Channels   types.Change[sql.NullInt64]
+	BitRate    types.Change[sql.NullInt64]
+	Codec      types.Change[sql.NullString]
+	FileSize   types.Change[sql.NullInt64]
+
+	Disc         types.Change[sql.NullInt64]
+	DiscSubtitle types.Change[sql.NullString]
+
+	RangeStart types.Change[sql.NullInt64]
+	RangeEnd   types.Change[sql.NullInt64]
+
+	Lyrics types.Change[sql.NullString]
+
+	TrackGain        types.Change[sql.NullFloat64]
+	TrackPeak        types.Change[sql.NullFloat64]
+	AlbumGain        types.Change[sql.NullFloat64]
+	AlbumPeak        types.Change[sql.NullFloat64]
+	LoudnessAnalyzed types.Change[sql.NullInt64]
+
+	ContentHash types.Change[sql.NullString]
+
+	Trashed types.Change[sql.NullInt64]
+
 	Created types.Change[int64]
 }
 
@@ -434,6 +644,34 @@ func (db DB) UpdateTrack(ctx context.Context, id string, changes TrackChanges) e
 	addToRecord(record, "number", changes.Number)
 	addToRecord(record, "year", changes.Year)
 
+	addToRecord(record, "release_date", changes.ReleaseDate)
+	addToRecord(record, "original_release_date", changes.OriginalReleaseDate)
+
+	addToRecord(record, "sample_rate", changes.SampleRate)
+	addToRecord(record, "bit_depth", changes.BitDepth)
+	addToRecord(record, "channels", changes.Channels)
+	addToRecord(record, "bit_rate", changes.BitRate)
+	addToRecord(record, "codec", changes.Codec)
+	addToRecord(record, "file_size", changes.FileSize)
+
+	addToRecord(record, "disc", changes.Disc)
+	addToRecord(record, "disc_subtitle", changes.DiscSubtitle)
+
+	addToRecord(record, "range_start", changes.RangeStart)
+	addToRecord(record, "range_end", changes.RangeEnd)
+
+	addToRecord(record, "lyrics", changes.Lyrics)
+
+	addToRecord(record, "track_gain", changes.TrackGain)
+	addToRecord(record, "track_peak", changes.TrackPeak)
+	addToRecord(record, "album_gain", changes.AlbumGain)
+	addToRecord(record, "album_peak", changes.AlbumPeak)
+	addToRecord(record, "loudness_analyzed", changes.LoudnessAnalyzed)
+
+	addToRecord(record, "content_hash", changes.ContentHash)
+
+	addToRecord(record, "trashed", changes.Trashed)
+
 	addToRecord(record, "created", changes.Created)
 
 	if len(record) == 0 {
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/nanoteck137/pyrin/ember"
+)
+
+// TrashAlbum moves the album to the trash, the album keeps the tags,
+// artists and tracks so it can be restored later
+func (db DB) TrashAlbum(ctx context.Context, id string) error {
+	ds := dialect.Update("albums").
+		Set(goqu.Record{
+			"trashed": time.Now().UnixMilli(),
+		}).
+		Where(
+			goqu.I("albums.id").Eq(id),
+			goqu.I("albums.trashed").IsNull(),
+		)
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db DB) RestoreAlbum(ctx context.Context, id string) error {
+	ds := dialect.Update("albums").
+		Set(goqu.Record{
+			"trashed": nil,
+		}).
+		Where(goqu.I("albums.id").Eq(id))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TrashTrack moves the track to the trash, the track keeps the playlist
+// memberships so it can be restored later
+func (db DB) TrashTrack(ctx context.Context, id string) error {
+	ds := dialect.Update("tracks").
+		Set(goqu.Record{
+			"trashed": time.Now().UnixMilli(),
+		}).
+		Where(
+			goqu.I("tracks.id").Eq(id),
+			goqu.I("tracks.trashed").IsNull(),
+		)
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db DB) RestoreTrack(ctx context.Context, id string) error {
+	ds := dialect.Update("tracks").
+		Set(goqu.Record{
+			"trashed": nil,
+		}).
+		Where(goqu.I("tracks.id").Eq(id))
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// trashRestoreWindow is how long before the album the tracks can be
+// trashed and still count as trashed together with the album
+const trashRestoreWindow = time.Minute
+
+// RestoreAllTracksByAlbum restores the tracks that was trashed together
+// with the album, the tracks trashed on their own before the album stays
+// inside the trash
+func (db DB) RestoreAllTracksByAlbum(ctx context.Context, albumId string, albumTrashed int64) error {
+	ds := dialect.Update("tracks").
+		Set(goqu.Record{
+			"trashed": nil,
+		}).
+		Where(
+			goqu.I("tracks.album_id").Eq(albumId),
+			goqu.I("tracks.trashed").Gte(albumTrashed-trashRestoreWindow.Milliseconds()),
+		)
+
+	_, err := db.db.Exec(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db DB) GetTrashedAlbums(ctx context.Context) ([]Album, error) {
+	query := AlbumQueryWithTrashed().
+		Where(goqu.I("albums.trashed").IsNotNull()).
+		Order(goqu.I("albums.trashed").Desc())
+
+	return ember.Multiple[Album](db.db, ctx, query)
+}
+
+func (db DB) GetTrashedTracks(ctx context.Context) ([]Track, error) {
+	query := TrackQueryWithTrashed().
+		Where(goqu.I("tracks.trashed").IsNotNull()).
+		Order(goqu.I("tracks.trashed").Desc())
+
+	return ember.Multiple[Track](db.db, ctx, query)
+}
+
+// GetAlbumTrackCount returns the number of tracks inside the album,
+// including the tracks inside the trash
+func (db DB) GetAlbumTrackCount(ctx context.Context, albumId string) (int, error) {
+	query := dialect.From("tracks").
+		Select(goqu.COUNT("tracks.id")).
+		Where(goqu.I("tracks.album_id").Eq(albumId))
+
+	return ember.Single[int](db.db, ctx, query)
+}
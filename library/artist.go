@@ -0,0 +1,146 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/tools/utils"
+)
+
+// ArtistsDirName is the directory inside the library root that contains
+// the artist directories, <library>/_artists/<slug>/artist.toml
+const ArtistsDirName = "_artists"
+
+type ArtistMetadata struct {
+	Name      string   `json:"name" toml:"name"`
+	OtherName string   `json:"otherName" toml:"otherName,omitempty"`
+	Picture   string   `json:"picture" toml:"picture,omitempty"`
+	Tags      []string `json:"tags" toml:"tags"`
+}
+
+type Artist struct {
+	Path string
+
+	// Slug is the name of the artist directory, used to match the artist
+	// inside the database
+	Slug string
+
+	Metadata ArtistMetadata
+}
+
+type ArtistSearch struct {
+	Artists []Artist
+	Errors  map[string]error
+}
+
+func readArtist(p string) (Artist, error) {
+	metadataPath := path.Join(p, "artist.toml")
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return Artist{}, err
+	}
+
+	metadata, err := decodeArtistMetadata(metadataPath, data, p)
+	if err != nil {
+		return Artist{}, err
+	}
+
+	slug := path.Base(p)
+	if utils.Slug(slug) != slug {
+		return Artist{}, ValidationErrors{
+			{
+				File:    metadataPath,
+				Message: fmt.Sprintf("artist directory %q is not a valid slug, expected %q", slug, utils.Slug(slug)),
+			},
+		}
+	}
+
+	// NOTE: Use the first image inside the directory if the picture is
+	// not set
+	if metadata.Picture == "" {
+		_, images, err := readAudioDir(p)
+		if err != nil {
+			return Artist{}, err
+		}
+
+		if len(images) > 0 {
+			sort.Strings(images)
+			metadata.Picture = path.Join(p, images[0])
+		}
+	}
+
+	return Artist{
+		Path:     p,
+		Slug:     slug,
+		Metadata: metadata,
+	}, nil
+}
+
+// FindArtists finds the artists inside the artists directory of `root`
+// that is affected by a search of `p`, searching the root or the artists
+// directory returns all the artists
+func FindArtists(p, root string) (*ArtistSearch, error) {
+	p = path.Clean(p)
+	artistsDir := path.Join(path.Clean(root), ArtistsDirName)
+
+	var dirs []string
+
+	switch {
+	case p == path.Clean(root) || p == artistsDir:
+		entries, err := os.ReadDir(artistsDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+
+			dirs = append(dirs, path.Join(artistsDir, e.Name()))
+		}
+	case strings.HasPrefix(p, artistsDir+"/"):
+		rel, err := filepath.Rel(artistsDir, p)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.Split(filepath.ToSlash(rel), "/")[0]
+		dirs = append(dirs, path.Join(artistsDir, name))
+	}
+
+	errors := map[string]error{}
+	res := make([]Artist, 0, len(dirs))
+
+	for _, dir := range dirs {
+		_, err := os.Stat(path.Join(dir, "artist.toml"))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errors[dir] = err
+			}
+
+			continue
+		}
+
+		artist, err := readArtist(dir)
+		if err != nil {
+			errors[dir] = err
+			continue
+		}
+
+		res = append(res, artist)
+	}
+
+	return &ArtistSearch{
+		Artists: res,
+		Errors:  errors,
+	}, nil
+}
@@ -0,0 +1,350 @@
+package library
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/tools/utils"
+)
+
+// cueFramesPerSecond is the number of frames per second used by the time
+// stamps inside a cue sheet, mm:ss:ff
+const cueFramesPerSecond = 75
+
+type CueTrack struct {
+	Number    int64
+	Title     string
+	Performer string
+
+	// Start is the offset of INDEX 01 inside the file in seconds
+	Start float64
+}
+
+type CueFile struct {
+	Name   string
+	Tracks []CueTrack
+}
+
+type CueSheet struct {
+	Title     string
+	Performer string
+	Date      string
+	Files     []CueFile
+}
+
+// parseCueTime parses a cue time stamp (mm:ss:ff) to seconds
+func parseCueTime(s string) (float64, error) {
+	splits := strings.Split(s, ":")
+	if len(splits) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	var values [3]int64
+	for i, split := range splits {
+		v, err := strconv.ParseInt(split, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+
+		values[i] = v
+	}
+
+	if values[1] >= 60 || values[2] >= cueFramesPerSecond {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	seconds := float64(values[0]*60+values[1]) + float64(values[2])/cueFramesPerSecond
+
+	// NOTE: Round to milliseconds, the same precision as the database
+	return math.Round(seconds*1000) / 1000, nil
+}
+
+// splitCueLine splits a cue line into the command and the arguments,
+// quoted arguments can contain spaces
+func splitCueLine(line string) (string, []string) {
+	var args []string
+
+	for {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end == -1 {
+				args = append(args, line[1:])
+				break
+			}
+
+			args = append(args, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			args = append(args, line)
+			break
+		}
+
+		args = append(args, line[:end])
+		line = line[end:]
+	}
+
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	return strings.ToUpper(args[0]), args[1:]
+}
+
+// ParseCue parses a cue sheet, only the information needed to create the
+// tracks is kept
+func ParseCue(data []byte) (CueSheet, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var sheet CueSheet
+
+	var file *CueFile
+	var track *CueTrack
+	hasIndex := false
+
+	finishTrack := func() error {
+		if track == nil {
+			return nil
+		}
+
+		if !hasIndex {
+			return fmt.Errorf("track %d is missing INDEX 01", track.Number)
+		}
+
+		file.Tracks = append(file.Tracks, *track)
+		track = nil
+
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		cmd, args := splitCueLine(scanner.Text())
+
+		arg := func(i int) string {
+			if i < len(args) {
+				return args[i]
+			}
+
+			return ""
+		}
+
+		switch cmd {
+		case "FILE":
+			err := finishTrack()
+			if err != nil {
+				return CueSheet{}, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			if arg(0) == "" {
+				return CueSheet{}, fmt.Errorf("line %d: FILE is missing the filename", lineNumber)
+			}
+
+			sheet.Files = append(sheet.Files, CueFile{
+				Name: arg(0),
+			})
+			file = &sheet.Files[len(sheet.Files)-1]
+		case "TRACK":
+			err := finishTrack()
+			if err != nil {
+				return CueSheet{}, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			if file == nil {
+				return CueSheet{}, fmt.Errorf("line %d: TRACK before FILE", lineNumber)
+			}
+
+			number, err := strconv.ParseInt(arg(0), 10, 64)
+			if err != nil {
+				return CueSheet{}, fmt.Errorf("line %d: invalid track number %q", lineNumber, arg(0))
+			}
+
+			track = &CueTrack{
+				Number: number,
+			}
+			hasIndex = false
+		case "INDEX":
+			if track == nil {
+				return CueSheet{}, fmt.Errorf("line %d: INDEX outside of TRACK", lineNumber)
+			}
+
+			// NOTE: INDEX 00 is the pregap, the track starts at INDEX 01
+			if arg(0) != "01" && arg(0) != "1" {
+				continue
+			}
+
+			start, err := parseCueTime(arg(1))
+			if err != nil {
+				return CueSheet{}, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			track.Start = start
+			hasIndex = true
+		case "TITLE":
+			if track != nil {
+				track.Title = arg(0)
+			} else {
+				sheet.Title = arg(0)
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = arg(0)
+			} else {
+				sheet.Performer = arg(0)
+			}
+		case "REM":
+			if strings.ToUpper(arg(0)) == "DATE" && track == nil {
+				sheet.Date = arg(1)
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return CueSheet{}, err
+	}
+
+	err = finishTrack()
+	if err != nil {
+		return CueSheet{}, fmt.Errorf("line %d: %w", lineNumber, err)
+	}
+
+	return sheet, nil
+}
+
+// resolveCueFile returns the track inside `tracks` referenced by the cue
+// sheet, the cue sheet commonly references the original rip (.wav) so a
+// track with the same name but another extension is also accepted
+func resolveCueFile(name string, tracks []string) (string, bool) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	for _, track := range tracks {
+		if track == name {
+			return track, true
+		}
+	}
+
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, track := range tracks {
+		if strings.TrimSuffix(track, path.Ext(track)) == base {
+			return track, true
+		}
+	}
+
+	return "", false
+}
+
+// cueSheetFile is a cue sheet read from the library, the content is kept
+// to create the fingerprint for the album
+type cueSheetFile struct {
+	Name  string
+	Data  []byte
+	Sheet CueSheet
+}
+
+// readCueSheets reads the cue sheets inside `dir`
+func readCueSheets(dir string) ([]cueSheetFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []cueSheetFile
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		if !utils.IsValidCueExt(path.Ext(e.Name())) {
+			continue
+		}
+
+		p := path.Join(dir, e.Name())
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		sheet, err := ParseCue(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cue sheet (%s): %w", p, err)
+		}
+
+		res = append(res, cueSheetFile{
+			Name:  e.Name(),
+			Data:  data,
+			Sheet: sheet,
+		})
+	}
+
+	return res, nil
+}
+
+// CueTracks creates the tracks for the files inside `tracks` that is
+// referenced by the cue sheets, the end of a track is the start of the
+// next track inside the same file. Returns the tracks and the files that
+// was used by the cue sheets.
+func CueTracks(sheets []CueSheet, tracks []string) ([]MetadataTrack, map[string]struct{}) {
+	var res []MetadataTrack
+	used := map[string]struct{}{}
+
+	for _, sheet := range sheets {
+		for _, file := range sheet.Files {
+			filename, found := resolveCueFile(file.Name, tracks)
+			if !found || len(file.Tracks) == 0 {
+				continue
+			}
+
+			used[filename] = struct{}{}
+
+			for i, track := range file.Tracks {
+				var end float64
+				if i+1 < len(file.Tracks) {
+					end = file.Tracks[i+1].Start
+				}
+
+				performer := track.Performer
+				if performer == "" {
+					performer = sheet.Performer
+				}
+
+				name := track.Title
+				if name == "" {
+					name = fmt.Sprintf("Track %d", track.Number)
+				}
+
+				res = append(res, MetadataTrack{
+					File:    filename,
+					Name:    name,
+					Number:  track.Number,
+					Start:   track.Start,
+					End:     end,
+					Tags:    []string{},
+					Artists: ParseArtists(performer),
+				})
+			}
+		}
+	}
+
+	return res, used
+}
@@ -0,0 +1,205 @@
+package library
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCueTime(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected float64
+		err      bool
+	}{
+		{s: "00:00:00", expected: 0},
+		{s: "01:02:00", expected: 62},
+		{s: "00:00:75", err: true},
+		{s: "00:00:74", expected: 0.987},
+		{s: "03:10:37", expected: 190.493},
+		{s: "100:00:00", expected: 6000},
+		{s: "00:60:00", err: true},
+		{s: "00:00", err: true},
+		{s: "00:-1:00", err: true},
+		{s: "aa:00:00", err: true},
+	}
+
+	for i, test := range tests {
+		v, err := parseCueTime(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("Test %d Failed: (\"%s\") Expected error got %v", i, test.s, v)
+			}
+
+			continue
+		}
+
+		if err != nil || v != test.expected {
+			t.Errorf("Test %d Failed: (\"%s\") Expected %v got %v (%v)", i, test.s, test.expected, v, err)
+		}
+	}
+}
+
+func TestSplitCueLine(t *testing.T) {
+	tests := []struct {
+		line string
+		cmd  string
+		args []string
+	}{
+		{line: `FILE "Some Album.flac" WAVE`, cmd: "FILE", args: []string{"Some Album.flac", "WAVE"}},
+		{line: `  title Unquoted`, cmd: "TITLE", args: []string{"Unquoted"}},
+		{line: "\tINDEX 01 00:00:00", cmd: "INDEX", args: []string{"01", "00:00:00"}},
+		{line: `PERFORMER ""`, cmd: "PERFORMER", args: []string{""}},
+		{line: `TITLE "Missing end`, cmd: "TITLE", args: []string{"Missing end"}},
+		{line: `REM DATE 1999`, cmd: "REM", args: []string{"DATE", "1999"}},
+		{line: "", cmd: "", args: nil},
+	}
+
+	for i, test := range tests {
+		cmd, args := splitCueLine(test.line)
+		if cmd != test.cmd || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test %d Failed: (%q) Expected %s %q got %s %q", i, test.line, test.cmd, test.args, cmd, args)
+		}
+	}
+}
+
+func TestParseCue(t *testing.T) {
+	data := "\xef\xbb\xbf" + `REM GENRE Rock
+REM DATE 1999
+PERFORMER "Album Artist"
+TITLE "Album"
+FILE "Disc 1.wav" WAVE
+  TRACK 01 AUDIO
+    TITLE "First"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Second"
+    PERFORMER "Guest"
+    INDEX 00 03:58:00
+    INDEX 01 04:00:37
+FILE "Disc 2.wav" WAVE
+  TRACK 03 AUDIO
+    TITLE Third
+    INDEX 01 00:00:00
+  TRACK 04 AUDIO
+    INDEX 01 02:30:00
+`
+
+	sheet, err := ParseCue([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse cue sheet: %v", err)
+	}
+
+	expected := CueSheet{
+		Title:     "Album",
+		Performer: "Album Artist",
+		Date:      "1999",
+		Files: []CueFile{
+			{
+				Name: "Disc 1.wav",
+				Tracks: []CueTrack{
+					{Number: 1, Title: "First", Start: 0},
+					{Number: 2, Title: "Second", Performer: "Guest", Start: 240.493},
+				},
+			},
+			{
+				Name: "Disc 2.wav",
+				Tracks: []CueTrack{
+					{Number: 3, Title: "Third", Start: 0},
+					{Number: 4, Start: 150},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(sheet, expected) {
+		t.Fatalf("Expected %+v got %+v", expected, sheet)
+	}
+
+	tracks, used := CueTracks([]CueSheet{sheet}, []string{"Disc 1.flac", "Disc 2.flac", "Bonus.flac"})
+
+	if _, exists := used["Bonus.flac"]; exists || len(used) != 2 {
+		t.Errorf("Expected only the referenced files to be used got %v", used)
+	}
+
+	expectedTracks := []struct {
+		file   string
+		name   string
+		artist string
+		start  float64
+		end    float64
+	}{
+		{file: "Disc 1.flac", name: "First", artist: "Album Artist", start: 0, end: 240.493},
+		// NOTE: The last track of a file is open ended (End 0)
+		{file: "Disc 1.flac", name: "Second", artist: "Guest", start: 240.493, end: 0},
+		{file: "Disc 2.flac", name: "Third", artist: "Album Artist", start: 0, end: 150},
+		{file: "Disc 2.flac", name: "Track 4", artist: "Album Artist", start: 150, end: 0},
+	}
+
+	if len(tracks) != len(expectedTracks) {
+		t.Fatalf("Expected %d tracks got %d", len(expectedTracks), len(tracks))
+	}
+
+	for i, e := range expectedTracks {
+		track := tracks[i]
+
+		if track.File != e.file || track.Name != e.name || track.Start != e.start || track.End != e.end {
+			t.Errorf("Track %d Failed: Expected %+v got %+v", i, e, track)
+		}
+
+		if !reflect.DeepEqual(track.Artists, []string{e.artist}) {
+			t.Errorf("Track %d Failed: Expected artist %q got %v", i, e.artist, track.Artists)
+		}
+	}
+}
+
+func TestParseCueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "track before file",
+			data: "TRACK 01 AUDIO\n",
+			err:  "line 1: TRACK before FILE",
+		},
+		{
+			name: "file without name",
+			data: "FILE\n",
+			err:  "line 1: FILE is missing the filename",
+		},
+		{
+			name: "invalid track number",
+			data: "FILE \"a.wav\" WAVE\nTRACK xx AUDIO\n",
+			err:  "line 2: invalid track number",
+		},
+		{
+			name: "index outside of track",
+			data: "FILE \"a.wav\" WAVE\nINDEX 01 00:00:00\n",
+			err:  "line 2: INDEX outside of TRACK",
+		},
+		{
+			name: "invalid index time",
+			data: "FILE \"a.wav\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:80\n",
+			err:  "line 3: invalid time",
+		},
+		{
+			name: "only pregap index",
+			data: "FILE \"a.wav\" WAVE\nTRACK 01 AUDIO\nINDEX 00 00:00:00\nTRACK 02 AUDIO\n",
+			err:  "line 4: track 1 is missing INDEX 01",
+		},
+		{
+			name: "last track without index",
+			data: "FILE \"a.wav\" WAVE\nTRACK 01 AUDIO\n",
+			err:  "track 1 is missing INDEX 01",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ParseCue([]byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: Expected error containing %q got %v", test.name, test.err, err)
+		}
+	}
+}
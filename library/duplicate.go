@@ -0,0 +1,62 @@
+package library
+
+type DuplicateKind string
+
+const (
+	DuplicateKindAlbum DuplicateKind = "album"
+	DuplicateKindTrack DuplicateKind = "track"
+)
+
+// Duplicate is an id that is used by more then one album or track,
+// `Path` is the album that used the id first and `OtherPath` the album
+// that used it again
+type Duplicate struct {
+	Kind      DuplicateKind
+	Id        string
+	Path      string
+	OtherPath string
+}
+
+// FindDuplicateIds finds the album and track ids that is used more then
+// once across `albums`, the result is in the same order as the albums
+func FindDuplicateIds(albums []Album) []Duplicate {
+	var res []Duplicate
+
+	albumIds := map[string]string{}
+	trackIds := map[string]string{}
+
+	for _, album := range albums {
+		id := album.Metadata.Album.Id
+		if id != "" {
+			if p, exists := albumIds[id]; exists {
+				res = append(res, Duplicate{
+					Kind:      DuplicateKindAlbum,
+					Id:        id,
+					Path:      p,
+					OtherPath: album.Path,
+				})
+			} else {
+				albumIds[id] = album.Path
+			}
+		}
+
+		for _, track := range album.Metadata.Tracks {
+			if track.Id == "" {
+				continue
+			}
+
+			if p, exists := trackIds[track.Id]; exists {
+				res = append(res, Duplicate{
+					Kind:      DuplicateKindTrack,
+					Id:        track.Id,
+					Path:      p,
+					OtherPath: album.Path,
+				})
+			} else {
+				trackIds[track.Id] = album.Path
+			}
+		}
+	}
+
+	return res
+}
@@ -1,12 +1,16 @@
 package library
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/nanoteck137/dwebble/tools/utils"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -15,35 +19,143 @@ type MetadataGeneral struct {
 	Tags      []string `json:"tags" toml:"tags"`
 	TrackTags []string `json:"trackTags" toml:"trackTags"`
 	Year      int64    `json:"year" toml:"year"`
+
+	// ReleaseDate and OriginalReleaseDate is YYYY, YYYY-MM or YYYY-MM-DD,
+	// used by the album and the tracks if they don't set the dates
+	ReleaseDate         string `json:"releaseDate" toml:"releaseDate,omitempty"`
+	OriginalReleaseDate string `json:"originalReleaseDate" toml:"originalReleaseDate,omitempty"`
 }
 
 type MetadataAlbum struct {
-	Id      string   `json:"id" toml:"id"`
-	Name    string   `json:"name" toml:"name"`
-	Year    int64    `json:"year" toml:"year"`
-	Tags    []string `json:"tags" toml:"tags"`
-	Artists []string `json:"artists" toml:"artists"`
+	Id        string   `json:"id" toml:"id"`
+	Name      string   `json:"name" toml:"name"`
+	OtherName string   `json:"otherName" toml:"otherName,omitempty"`
+	Year      int64    `json:"year" toml:"year"`
+	Tags      []string `json:"tags" toml:"tags"`
+	Artists   []string `json:"artists" toml:"artists"`
+
+	// ReleaseDate is the full or partial date of the release (YYYY,
+	// YYYY-MM or YYYY-MM-DD), the year is used if not set.
+	// OriginalReleaseDate is the date of the first release for reissues.
+	ReleaseDate         string `json:"releaseDate" toml:"releaseDate,omitempty"`
+	OriginalReleaseDate string `json:"originalReleaseDate" toml:"originalReleaseDate,omitempty"`
+
+	// ReleaseType is the kind of release (album, single, ep, compilation,
+	// live, soundtrack...), albums without a type is treated as an album.
+	// Compilations doesn't need any artists, the album is credited to
+	// "Various Artists"
+	ReleaseType string `json:"releaseType" toml:"releaseType,omitempty"`
 }
 
 type MetadataTrack struct {
-	Id      string   `json:"id" toml:"id"`
-	File    string   `json:"file" toml:"file"`
-	Name    string   `json:"name" toml:"name"`
-	Number  int64    `json:"number" toml:"number"`
-	Year    int64    `json:"year" toml:"year"`
+	Id        string `json:"id" toml:"id"`
+	File      string `json:"file" toml:"file"`
+	Name      string `json:"name" toml:"name"`
+	OtherName string `json:"otherName" toml:"otherName,omitempty"`
+	Number    int64  `json:"number" toml:"number"`
+	Year      int64  `json:"year" toml:"year"`
+
+	// ReleaseDate and OriginalReleaseDate is the same as the album, the
+	// dates of the album is used if not set
+	ReleaseDate         string `json:"releaseDate" toml:"releaseDate,omitempty"`
+	OriginalReleaseDate string `json:"originalReleaseDate" toml:"originalReleaseDate,omitempty"`
+
+	Disc         int64  `json:"disc" toml:"disc,omitempty"`
+	DiscSubtitle string `json:"discSubtitle" toml:"discSubtitle,omitempty"`
+
+	// Start and End is the range of the track inside the file in seconds,
+	// used when multiple tracks shares a single file. End 0 is the end of
+	// the file
+	Start float64 `json:"start" toml:"start,omitempty"`
+	End   float64 `json:"end" toml:"end,omitempty"`
+
+	// Lyrics is plain or LRC lyrics, if not set the .lrc or .txt file
+	// next to the track file is used
+	Lyrics string `json:"lyrics" toml:"lyrics,omitempty"`
+
 	Tags    []string `json:"tags" toml:"tags"`
 	Artists []string `json:"artists" toml:"artists"`
 }
 
+// HasRange reports if the track only uses a range of the file
+func (t *MetadataTrack) HasRange() bool {
+	return t.Start != 0 || t.End != 0
+}
+
+// lyricsSidecars returns the possible lyrics files for the track, tracks
+// with a range shares the file so they can't use a sidecar
+func (t *MetadataTrack) lyricsSidecars() []string {
+	if t.HasRange() || t.File == "" {
+		return nil
+	}
+
+	base := strings.TrimSuffix(t.File, path.Ext(t.File))
+
+	return []string{base + ".lrc", base + ".txt"}
+}
+
+// ReadLyrics returns the lyrics for the track, the lyrics from the
+// metadata is used before the sidecar files. Returns an empty string if
+// the track has no lyrics.
+func ReadLyrics(track *MetadataTrack) (string, error) {
+	if track.Lyrics != "" {
+		return track.Lyrics, nil
+	}
+
+	for _, p := range track.lyricsSidecars() {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", err
+		}
+
+		return string(data), nil
+	}
+
+	return "", nil
+}
+
+// MetadataArtist sets extra information for an artist used by the album,
+// the artist is matched by name
+type MetadataArtist struct {
+	Name      string `json:"name" toml:"name"`
+	OtherName string `json:"otherName" toml:"otherName,omitempty"`
+}
+
 type Metadata struct {
-	General MetadataGeneral `json:"general" toml:"general"`
-	Album   MetadataAlbum   `json:"album" toml:"album"`
-	Tracks  []MetadataTrack `json:"tracks" toml:"tracks"`
+	General MetadataGeneral  `json:"general" toml:"general"`
+	Album   MetadataAlbum    `json:"album" toml:"album"`
+	Tracks  []MetadataTrack  `json:"tracks" toml:"tracks"`
+	Artists []MetadataArtist `json:"artists" toml:"artists,omitempty"`
 }
 
 type Album struct {
 	Path     string
 	Metadata Metadata
+
+	// Fingerprint is a hash of the album.toml content and the modified
+	// time of the files it references, used to detect unchanged albums
+	Fingerprint string
+
+	// Tagged is set for albums without an album.toml, the metadata is
+	// created from the embedded tags when Load is called
+	Tagged bool
+
+	// Root is the name of the library root the album was found in
+	Root string
+
+	loaded bool
+	tracks []string
+	images []string
+
+	// NOTE: Tracks from the cue sheets, the files used by the cue sheets
+	// is not included inside tracks
+	cues      []CueSheet
+	cueTracks []MetadataTrack
+	cueFiles  []string
 }
 
 type Library struct {
@@ -60,6 +172,23 @@ type Search struct {
 	Errors map[string]error
 }
 
+type SearchOptions struct {
+	// ImportTagged treats directories with tracks but without an
+	// album.toml as albums
+	ImportTagged bool
+
+	// Root is the library root, used to create stable ids for the tagged
+	// albums, defaults to the search path
+	Root string
+
+	// RootName is the name of the library root, set on the albums found
+	RootName string
+
+	// TaggedIdPrefix is added to the paths used to create the ids for the
+	// tagged albums, keeps the ids unique between multiple roots
+	TaggedIdPrefix string
+}
+
 func readAlbum(p string) (Album, error) {
 	metadataPath := path.Join(p, "album.toml")
 	data, err := os.ReadFile(metadataPath)
@@ -67,40 +196,107 @@ func readAlbum(p string) (Album, error) {
 		return Album{}, err
 	}
 
-	var metadata Metadata
-	err = toml.Unmarshal(data, &metadata)
+	metadata, err := decodeMetadata(metadataPath, data, p)
 	if err != nil {
 		return Album{}, err
 	}
 
+	return Album{
+		Path:        p,
+		Metadata:    metadata,
+		Fingerprint: createFingerprint(data, &metadata),
+	}, nil
+}
+
+// resolveMetadataPaths makes the file paths inside the metadata relative to
+// the album directory
+func resolveMetadataPaths(metadata *Metadata, dir string) {
 	if metadata.General.Cover != "" {
-		metadata.General.Cover = path.Join(p, metadata.General.Cover)
+		metadata.General.Cover = path.Join(dir, metadata.General.Cover)
 	}
 
 	for i, t := range metadata.Tracks {
-		metadata.Tracks[i].File = path.Join(p, t.File)
+		if t.File != "" {
+			metadata.Tracks[i].File = path.Join(dir, t.File)
+		}
 	}
+}
 
-	return Album{
-		Path:     p,
-		Metadata: metadata,
-	}, nil
+func createFingerprint(data []byte, metadata *Metadata) string {
+	hash := sha256.New()
+	hash.Write(data)
+
+	writeModifiedTime := func(p string) {
+		stat, err := os.Stat(p)
+		if err != nil {
+			fmt.Fprintf(hash, "%s:missing\n", p)
+			return
+		}
+
+		fmt.Fprintf(hash, "%s:%d\n", p, stat.ModTime().UnixMilli())
+	}
+
+	if metadata.General.Cover != "" {
+		writeModifiedTime(metadata.General.Cover)
+	}
+
+	for _, t := range metadata.Tracks {
+		writeModifiedTime(t.File)
+
+		// NOTE: Only the existing sidecars is added so albums without
+		// lyrics keeps the same fingerprint
+		for _, p := range t.lyricsSidecars() {
+			if _, err := os.Stat(p); err == nil {
+				writeModifiedTime(p)
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hasAlbumParent reports if `dir` or any of the parents up to `root` has
+// an album.toml
+func hasAlbumParent(dir, root string) bool {
+	for {
+		_, err := os.Stat(path.Join(dir, "album.toml"))
+		if err == nil {
+			return true
+		}
+
+		if dir == root || !strings.HasPrefix(dir, root) {
+			return false
+		}
+
+		dir = path.Dir(dir)
+	}
 }
 
-func FindAlbums(p string) (*Search, error) {
+func FindAlbums(p string, options SearchOptions) (*Search, error) {
 	var albums []string
+	var audioDirs []string
+
+	seenAudioDirs := map[string]struct{}{}
+
+	searchRoot := p
 
 	err := filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
 		if d == nil {
 			return nil
 		}
 
+		name := d.Name()
+
 		if d.IsDir() {
+			// NOTE: The artists directory contains artist.toml files and
+			// not albums
+			if name == ArtistsDirName {
+				return filepath.SkipDir
+			}
+
 			return nil
 		}
 
-		name := d.Name()
-
 		if strings.HasPrefix(name, ".") {
 			return nil
 		}
@@ -109,6 +305,21 @@ func FindAlbums(p string) (*Search, error) {
 			albums = append(albums, path.Dir(p))
 		}
 
+		if options.ImportTagged && utils.IsValidTrackExt(path.Ext(name)) {
+			dir := path.Dir(p)
+
+			// NOTE: Skip tracks inside hidden directories
+			rel, _ := filepath.Rel(searchRoot, dir)
+			if strings.HasPrefix(rel, ".") && rel != "." || strings.Contains(rel, "/.") {
+				return nil
+			}
+
+			if _, seen := seenAudioDirs[dir]; !seen {
+				seenAudioDirs[dir] = struct{}{}
+				audioDirs = append(audioDirs, dir)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -125,9 +336,46 @@ func FindAlbums(p string) (*Search, error) {
 			continue
 		}
 
+		album.Root = options.RootName
 		res = append(res, album)
 	}
 
+	if options.ImportTagged {
+		root := options.Root
+		if root == "" {
+			root = p
+		}
+
+		root = path.Clean(root)
+
+		for _, dir := range audioDirs {
+			if hasAlbumParent(dir, root) {
+				continue
+			}
+
+			tracks, images, err := readAudioDir(dir)
+			if err != nil {
+				errors[dir] = err
+				continue
+			}
+
+			cues, err := readCueSheets(dir)
+			if err != nil {
+				errors[dir] = err
+				continue
+			}
+
+			album, err := readTaggedAlbum(dir, root, options.TaggedIdPrefix, tracks, images, cues)
+			if err != nil {
+				errors[dir] = err
+				continue
+			}
+
+			album.Root = options.RootName
+			res = append(res, album)
+		}
+	}
+
 	return &Search{
 		Albums: res,
 		Errors: errors,
@@ -139,6 +387,18 @@ type FileNode struct {
 	Path     string     `json:"path"`
 	IsDir    bool       `json:"isDir"`
 	Children []FileNode `json:"children,omitempty"`
+
+	// HasAlbumFile is set if the directory contains an album.toml,
+	// AlbumError is set if it failed to parse or failed the validation on
+	// the last sync and AlbumId is the id from the file
+	HasAlbumFile bool   `json:"hasAlbumFile"`
+	AlbumError   string `json:"albumError,omitempty"`
+	AlbumId      string `json:"albumId,omitempty"`
+
+	// NumTracks is the number of audio files inside the directory and
+	// NumUnreferencedTracks is the audio files not used by any album.toml
+	NumTracks             int `json:"numTracks"`
+	NumUnreferencedTracks int `json:"numUnreferencedTracks"`
 }
 
 func includeFile(name string) bool {
@@ -146,23 +406,87 @@ func includeFile(name string) bool {
 	// return name == "album.toml"
 }
 
-// BuildDirTree builds a directory tree starting from `root`
-func BuildDirTree(root string) (FileNode, error) {
+// BuildDirTree builds a directory tree starting from `root`, the
+// directories is annotated with the album.toml status and the audio files
+// that isn't used by any album. The album.toml files isn't validated,
+// `albumErrors` is the errors from the last sync keyed by the absolute
+// path of the album.toml
+func BuildDirTree(root string, albumErrors map[string]string) (FileNode, error) {
 	absRoot, err := filepath.Abs(root)
 	if err != nil {
 		return FileNode{}, err
 	}
 
-	return buildTreeRecursive(absRoot, absRoot)
+	t := dirTree{
+		root:        absRoot,
+		albumErrors: albumErrors,
+		referenced:  map[string]struct{}{},
+	}
+
+	return t.build(absRoot)
+}
+
+type dirTree struct {
+	root        string
+	albumErrors map[string]string
+	referenced  map[string]struct{}
 }
 
-func buildTreeRecursive(root, current string) (FileNode, error) {
+// albumFileRefs is the part of the album.toml used by the directory tree
+type albumFileRefs struct {
+	Album struct {
+		Id string `toml:"id"`
+	} `toml:"album"`
+	Tracks []struct {
+		File string `toml:"file"`
+	} `toml:"tracks"`
+}
+
+// annotateAlbumDir sets the album status for the directory, the track
+// files used by the album.toml is added to the referenced files
+func (t *dirTree) annotateAlbumDir(node *FileNode, dir string) {
+	metadataPath := path.Join(dir, "album.toml")
+
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			node.HasAlbumFile = true
+			node.AlbumError = err.Error()
+		}
+
+		return
+	}
+
+	node.HasAlbumFile = true
+
+	// NOTE: Only the id and the files is read, the full validation stats
+	// every file so the errors is taken from the last sync instead
+	var refs albumFileRefs
+	err = toml.Unmarshal(data, &refs)
+	if err != nil {
+		node.AlbumError = fmt.Sprintf("%s: %v", metadataPath, err)
+		return
+	}
+
+	node.AlbumError = t.albumErrors[metadataPath]
+	node.AlbumId = refs.Album.Id
+
+	for _, track := range refs.Tracks {
+		if track.File != "" {
+			t.referenced[path.Join(dir, track.File)] = struct{}{}
+		}
+	}
+}
+
+// NOTE: The parents is visited before the children, so the files used by
+// an album.toml inside a parent is marked as referenced
+func (t *dirTree) build(current string) (FileNode, error) {
 	info, err := os.Stat(current)
 	if err != nil {
 		return FileNode{}, err
 	}
 
-	relPath, _ := filepath.Rel(root, current)
+	relPath, _ := filepath.Rel(t.root, current)
 	if relPath == "." {
 		relPath = "/" // root
 	} else {
@@ -176,16 +500,33 @@ func buildTreeRecursive(root, current string) (FileNode, error) {
 	}
 
 	if info.IsDir() {
+		t.annotateAlbumDir(&node, current)
+
 		entries, err := os.ReadDir(current)
 		if err != nil {
 			return node, err
 		}
 
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			if utils.IsValidTrackExt(path.Ext(entry.Name())) {
+				node.NumTracks++
+
+				p := path.Join(current, entry.Name())
+				if _, exists := t.referenced[p]; !exists {
+					node.NumUnreferencedTracks++
+				}
+			}
+		}
+
 		for _, entry := range entries {
 			childPath := filepath.Join(current, entry.Name())
 
 			if entry.IsDir() || includeFile(entry.Name()) {
-				childNode, err := buildTreeRecursive(root, childPath)
+				childNode, err := t.build(childPath)
 				if err != nil {
 					return node, err
 				}
@@ -0,0 +1,394 @@
+package library
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+)
+
+var dateRegex = regexp.MustCompile(`^([12]\d\d\d)`)
+
+func ParseArtists(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+
+	splits := strings.Split(s, ",")
+
+	artists := make([]string, 0, len(splits))
+	for _, s := range splits {
+		a := strings.TrimSpace(s)
+
+		if a != "" {
+			artists = append(artists, a)
+		}
+	}
+
+	return artists
+}
+
+// ParseYear returns the year from a date tag, returns 0 if the tag doesn't
+// start with a year
+func ParseYear(s string) int64 {
+	match := dateRegex.FindStringSubmatch(s)
+	if len(match) > 0 {
+		year, _ := strconv.ParseInt(match[1], 10, 64)
+		return year
+	}
+
+	return 0
+}
+
+// ParseReleaseType returns the release type from a release type tag,
+// tags from MusicBrainz can contain the primary and secondary types like
+// "album; compilation" and the last known type is used. Returns an empty
+// string if the tag has no known type.
+func ParseReleaseType(s string) string {
+	var res string
+
+	splits := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ';' || r == ',' || r == '/'
+	})
+
+	for _, split := range splits {
+		if t, ok := types.ParseReleaseType(split); ok {
+			res = string(t)
+		}
+	}
+
+	return res
+}
+
+// ParseNumber returns the number from a track or disc tag, tags like
+// "1/12" returns 1
+func ParseNumber(s string) int64 {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "/")
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// MetadataFromTags creates the metadata for the album inside `dir` from
+// the embedded tags of the tracks, `tracks` and `images` are filenames
+// relative to `dir`. The ids are left empty.
+func MetadataFromTags(dir string, tracks []string, images []string) (Metadata, error) {
+	metadata, _, err := metadataFromTags(dir, tracks, images)
+	return metadata, err
+}
+
+// metadataFromTags is MetadataFromTags but also returns the probe results
+// by the track path, so the tracks doesn't need to be probed again
+func metadataFromTags(dir string, tracks []string, images []string) (Metadata, map[string]utils.ProbeResult, error) {
+	metadata := Metadata{}
+
+	if len(tracks) <= 0 {
+		return metadata, nil, nil
+	}
+
+	probes := make(map[string]utils.ProbeResult, len(tracks))
+	for _, filename := range tracks {
+		p := path.Join(dir, filename)
+
+		probe, err := utils.ProbeTrack(p)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("failed to probe track (%s): %w", filename, err)
+		}
+
+		probes[p] = probe
+	}
+
+	// NOTE: The album information is read from the first track
+	probe := probes[path.Join(dir, tracks[0])]
+
+	isSingle := len(tracks) == 1
+
+	if len(images) > 0 {
+		// TODO(patrik): Better selection?
+		metadata.General.Cover = images[0]
+	}
+
+	if !isSingle {
+		metadata.Album.Name, _ = probe.Tags.GetString("album")
+	} else {
+		// NOTE(patrik): If we only have one track then we make the
+		// album name the same as the track name
+		metadata.Album.Name, _ = probe.Tags.GetString("title")
+	}
+
+	if !isSingle {
+		if tag, err := probe.Tags.GetString("album_artist"); err == nil {
+			metadata.Album.Artists = ParseArtists(tag)
+		} else {
+			if tag, err := probe.Tags.GetString("artist"); err == nil {
+				metadata.Album.Artists = ParseArtists(tag)
+			}
+		}
+	} else {
+		if tag, err := probe.Tags.GetString("artist"); err == nil {
+			metadata.Album.Artists = ParseArtists(tag)
+		}
+	}
+
+	if tag, err := probe.Tags.GetString("date"); err == nil {
+		metadata.General.Year = ParseYear(tag)
+		metadata.General.ReleaseDate = utils.ParseReleaseDateTag(tag)
+	}
+
+	for _, key := range []string{"originaldate", "original_date", "originalyear"} {
+		if tag, err := probe.Tags.GetString(key); err == nil {
+			metadata.General.OriginalReleaseDate = utils.ParseReleaseDateTag(tag)
+			break
+		}
+	}
+
+	for _, key := range []string{"releasetype", "musicbrainz album type", "musicbrainz_albumtype"} {
+		if tag, err := probe.Tags.GetString(key); err == nil {
+			metadata.Album.ReleaseType = ParseReleaseType(tag)
+			break
+		}
+	}
+
+	// NOTE: The compilation flag is set by iTunes and most taggers, the
+	// album artist is replaced by "Various Artists" when syncing
+	if tag, err := probe.Tags.GetString("compilation"); err == nil && strings.TrimSpace(tag) == "1" {
+		metadata.Album.ReleaseType = string(types.ReleaseTypeCompilation)
+	}
+
+	for _, filename := range tracks {
+		probe := probes[path.Join(dir, filename)]
+
+		name, _ := probe.Tags.GetString("title")
+		if name == "" {
+			name = strings.TrimSuffix(filename, path.Ext(filename))
+		}
+
+		var number int64
+		if tag, err := probe.Tags.GetInt("track"); err == nil {
+			number = tag
+		}
+
+		if number == 0 {
+			number = int64(utils.ExtractNumber(filename))
+		}
+
+		var disc int64
+		if tag, err := probe.Tags.GetString("disc"); err == nil {
+			disc = ParseNumber(tag)
+		}
+
+		// TODO(patrik): If artist is empty then use album maybe
+		artist, _ := probe.Tags.GetString("artist")
+
+		metadata.Tracks = append(metadata.Tracks, MetadataTrack{
+			File:    filename,
+			Name:    name,
+			Number:  number,
+			Disc:    disc,
+			Year:    0,
+			Tags:    []string{},
+			Artists: ParseArtists(artist),
+		})
+	}
+
+	if metadata.Album.Name == "" {
+		abs, err := filepath.Abs(dir)
+		if err == nil {
+			metadata.Album.Name = filepath.Base(abs)
+		}
+	}
+
+	return metadata, probes, nil
+}
+
+// createTaggedId creates a deterministic id from the path relative to the
+// library root, so the same directory gets the same ids on every sync
+func createTaggedId(kind, rel string, length int) string {
+	hash := sha256.Sum256([]byte(kind + ":" + rel))
+	return hex.EncodeToString(hash[:])[:length]
+}
+
+// readTaggedAlbum creates an album for a directory without an album.toml,
+// the tracks are not probed until Load is called. Files referenced by the
+// cue sheets is split into one track per cue track.
+func readTaggedAlbum(p, root, idPrefix string, tracks, images []string, cues []cueSheetFile) (Album, error) {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return Album{}, err
+	}
+
+	rel = idPrefix + filepath.ToSlash(rel)
+
+	sort.Strings(tracks)
+	sort.Strings(images)
+
+	sheets := make([]CueSheet, 0, len(cues))
+	for _, cue := range cues {
+		sheets = append(sheets, cue.Sheet)
+	}
+
+	cueTracks, used := CueTracks(sheets, tracks)
+
+	var cueFiles []string
+	var plainTracks []string
+
+	for _, filename := range tracks {
+		if _, exists := used[filename]; exists {
+			cueFiles = append(cueFiles, filename)
+		} else {
+			plainTracks = append(plainTracks, filename)
+		}
+	}
+
+	var metadata Metadata
+	metadata.Album.Id = createTaggedId("album", rel, 16)
+
+	for _, track := range cueTracks {
+		key := fmt.Sprintf("%s#%d", path.Join(rel, track.File), track.Number)
+
+		metadata.Tracks = append(metadata.Tracks, MetadataTrack{
+			Id:    createTaggedId("track", key, 32),
+			File:  path.Join(p, track.File),
+			Start: track.Start,
+			End:   track.End,
+		})
+	}
+
+	for _, filename := range plainTracks {
+		metadata.Tracks = append(metadata.Tracks, MetadataTrack{
+			Id:   createTaggedId("track", path.Join(rel, filename), 32),
+			File: path.Join(p, filename),
+		})
+	}
+
+	if len(images) > 0 {
+		metadata.General.Cover = path.Join(p, images[0])
+	}
+
+	// NOTE: Use the filenames as the content, the modified times are added
+	// by createFingerprint
+	data := []byte("tagged\n" + strings.Join(tracks, "\n") + "\n" + strings.Join(images, "\n"))
+
+	for _, cue := range cues {
+		data = append(data, "\n"+cue.Name+"\n"...)
+		data = append(data, cue.Data...)
+	}
+
+	return Album{
+		Path:        p,
+		Metadata:    metadata,
+		Fingerprint: createFingerprint(data, &metadata),
+		Tagged:      true,
+		tracks:      plainTracks,
+		images:      images,
+		cues:        sheets,
+		cueTracks:   cueTracks,
+		cueFiles:    cueFiles,
+	}, nil
+}
+
+// Load reads the embedded tags for albums created from the tags, albums
+// with an album.toml is already loaded. Returns the probe results by the
+// track file for the files probed while reading the tags.
+func (a *Album) Load() (map[string]utils.ProbeResult, error) {
+	if !a.Tagged || a.loaded {
+		return nil, nil
+	}
+
+	// NOTE: Albums with only cue tracks reads the album information from
+	// the tags of the files used by the cue sheets
+	files := a.tracks
+	if len(files) == 0 {
+		files = a.cueFiles
+	}
+
+	metadata, probes, err := metadataFromTags(a.Path, files, a.images)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.cueTracks) > 0 {
+		if len(a.tracks) == 0 {
+			metadata.Tracks = nil
+		}
+
+		applyCueSheet(&metadata, &a.cues[0])
+
+		tracks := make([]MetadataTrack, 0, len(a.cueTracks)+len(metadata.Tracks))
+		tracks = append(tracks, a.cueTracks...)
+		tracks = append(tracks, metadata.Tracks...)
+		metadata.Tracks = tracks
+	}
+
+	metadata.Album.Id = a.Metadata.Album.Id
+	metadata.General.Cover = a.Metadata.General.Cover
+
+	for i := range metadata.Tracks {
+		metadata.Tracks[i].Id = a.Metadata.Tracks[i].Id
+		metadata.Tracks[i].File = a.Metadata.Tracks[i].File
+	}
+
+	a.Metadata = metadata
+	a.loaded = true
+
+	return probes, nil
+}
+
+// applyCueSheet sets the album information from the cue sheet, the
+// information from the tags is kept if the cue sheet is missing it
+func applyCueSheet(metadata *Metadata, sheet *CueSheet) {
+	if sheet.Title != "" {
+		metadata.Album.Name = sheet.Title
+	}
+
+	if sheet.Performer != "" {
+		metadata.Album.Artists = ParseArtists(sheet.Performer)
+	}
+
+	if year := ParseYear(sheet.Date); year != 0 {
+		metadata.General.Year = year
+		metadata.General.ReleaseDate = utils.ParseReleaseDateTag(sheet.Date)
+	}
+}
+
+// readAudioDir returns the track and image filenames inside `dir`
+func readAudioDir(dir string) ([]string, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tracks []string
+	var images []string
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		ext := path.Ext(e.Name())
+
+		if utils.IsValidTrackExt(ext) {
+			tracks = append(tracks, e.Name())
+		}
+
+		if utils.IsValidImageExt(ext) {
+			images = append(images, e.Name())
+		}
+	}
+
+	return tracks, images, nil
+}
@@ -0,0 +1,411 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/nanoteck137/dwebble/tools/utils"
+	"github.com/nanoteck137/dwebble/types"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+var idRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidationError is a problem found inside an album.toml or artist.toml,
+// Line and Column is 0 if the location is unknown
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+
+	// FullMessage is the message with the surrounding lines, only set for
+	// the syntax and type errors
+	FullMessage string
+}
+
+func (e ValidationError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+
+	return strings.Join(s, "\n")
+}
+
+type keyPosition struct {
+	line   int
+	column int
+}
+
+// findKeyPositions maps the keys inside the document to the location they
+// are defined at, array tables is indexed like "tracks[1].name"
+func findKeyPositions(data []byte) map[string]keyPosition {
+	res := map[string]keyPosition{}
+	arrayCount := map[string]int{}
+
+	joinKey := func(it unstable.Iterator) (string, *unstable.Node) {
+		var parts []string
+		var first *unstable.Node
+
+		for it.Next() {
+			node := it.Node()
+			if first == nil {
+				first = node
+			}
+
+			parts = append(parts, string(node.Data))
+		}
+
+		return strings.Join(parts, "."), first
+	}
+
+	p := unstable.Parser{}
+	p.Reset(data)
+
+	prefix := ""
+
+	for p.NextExpression() {
+		expr := p.Expression()
+
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			key, first := joinKey(expr.Key())
+
+			if expr.Kind == unstable.ArrayTable {
+				index := arrayCount[key]
+				arrayCount[key] = index + 1
+				prefix = fmt.Sprintf("%s[%d]", key, index)
+			} else {
+				prefix = key
+			}
+
+			if first != nil {
+				shape := p.Shape(first.Raw)
+				res[prefix] = keyPosition{shape.Start.Line, shape.Start.Column}
+			}
+		case unstable.KeyValue:
+			key, first := joinKey(expr.Key())
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			if first != nil {
+				shape := p.Shape(first.Raw)
+				res[key] = keyPosition{shape.Start.Line, shape.Start.Column}
+			}
+		}
+	}
+
+	return res
+}
+
+type validator struct {
+	file      string
+	positions map[string]keyPosition
+	errs      ValidationErrors
+}
+
+// addError adds an error located at `key`, if the key isn't defined the
+// closest parent is used instead
+func (v *validator) addError(key string, format string, args ...any) {
+	err := ValidationError{
+		File:    v.file,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	for key != "" {
+		if pos, exists := v.positions[key]; exists {
+			err.Line = pos.line
+			err.Column = pos.column
+			break
+		}
+
+		i := strings.LastIndexAny(key, ".[")
+		if i == -1 {
+			break
+		}
+
+		key = key[:i]
+	}
+
+	v.errs = append(v.errs, err)
+}
+
+// decode decodes `data` into `out`, unknown keys is added as errors.
+// Syntax and type errors is returned as ValidationErrors because the rest
+// of the document can't be validated.
+func (v *validator) decode(data []byte, out any) error {
+	decoder := toml.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+
+	v.positions = findKeyPositions(data)
+
+	err := decoder.Decode(out)
+	if err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column := decodeErr.Position()
+			v.errs = append(v.errs, ValidationError{
+				File:    v.file,
+				Line:    line,
+				Column:  column,
+				Message: decodeErr.Error(),
+
+				FullMessage: decodeErr.String(),
+			})
+
+			return v.errs
+		}
+
+		var strictErr *toml.StrictMissingError
+		if !errors.As(err, &strictErr) {
+			return err
+		}
+
+		for _, e := range strictErr.Errors {
+			line, column := e.Position()
+			v.errs = append(v.errs, ValidationError{
+				File:    v.file,
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("unknown key %q", strings.Join(e.Key(), ".")),
+			})
+		}
+	}
+
+	return nil
+}
+
+// decodeMetadata decodes the album.toml and validates the result, unknown
+// keys and invalid values is returned as ValidationErrors
+func decodeMetadata(file string, data []byte, dir string) (Metadata, error) {
+	var metadata Metadata
+
+	v := validator{
+		file: file,
+	}
+
+	err := v.decode(data, &metadata)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	resolveMetadataPaths(&metadata, dir)
+
+	v.validate(&metadata)
+
+	if len(v.errs) > 0 {
+		return Metadata{}, v.errs
+	}
+
+	return metadata, nil
+}
+
+// validateReleaseDate checks that the date is a valid full or partial
+// date and that it matches the year if both is set
+func (v *validator) validateReleaseDate(key, name, date string, year int64) {
+	if date == "" {
+		return
+	}
+
+	normalized, err := utils.NormalizeReleaseDate(date)
+	if err != nil {
+		v.addError(key, "%s: %v", name, err)
+		return
+	}
+
+	if year != 0 && utils.ReleaseDateYear(normalized) != year {
+		v.addError(key, "%s %q doesn't match the year %d", name, date, year)
+	}
+}
+
+func (v *validator) validate(metadata *Metadata) {
+	album := &metadata.Album
+
+	if !idRegex.MatchString(album.Id) {
+		v.addError("album.id", "album id %q is not valid", album.Id)
+	}
+
+	if strings.TrimSpace(album.Name) == "" {
+		v.addError("album.name", "album name is required")
+	}
+
+	v.validateReleaseDate("general.releaseDate", "general release date", metadata.General.ReleaseDate, metadata.General.Year)
+	v.validateReleaseDate("general.originalReleaseDate", "general original release date", metadata.General.OriginalReleaseDate, 0)
+	v.validateReleaseDate("album.releaseDate", "album release date", album.ReleaseDate, album.Year)
+	v.validateReleaseDate("album.originalReleaseDate", "album original release date", album.OriginalReleaseDate, 0)
+
+	releaseType, validType := types.ParseReleaseType(album.ReleaseType)
+	if album.ReleaseType != "" && !validType {
+		v.addError("album.releaseType", "album release type %q is not valid", album.ReleaseType)
+	}
+
+	// NOTE: Compilations without any artists is credited to "Various
+	// Artists"
+	if len(album.Artists) == 0 && releaseType != types.ReleaseTypeCompilation {
+		v.addError("album.artists", "album needs at least one artist")
+	}
+
+	for i, artist := range album.Artists {
+		if strings.TrimSpace(artist) == "" {
+			v.addError("album.artists", "album artist[%d] is empty", i)
+		}
+	}
+
+	if metadata.General.Cover != "" {
+		_, err := os.Stat(metadata.General.Cover)
+		if err != nil {
+			v.addError("general.cover", "cover %q doesn't exist", metadata.General.Cover)
+		}
+	}
+
+	for i, artist := range metadata.Artists {
+		if strings.TrimSpace(artist.Name) == "" {
+			v.addError(fmt.Sprintf("artists[%d].name", i), "artists[%d] name is required", i)
+		}
+	}
+
+	// NOTE: Track numbers only needs to be unique inside the same disc,
+	// tracks without a disc is treated as disc 1
+	type discNumber struct {
+		disc   int64
+		number int64
+	}
+
+	numbers := map[discNumber]int{}
+
+	// NOTE: Multiple tracks can use the same file if all of them sets a
+	// range inside the file
+	files := map[string]int{}
+
+	for i, track := range metadata.Tracks {
+		key := fmt.Sprintf("tracks[%d]", i)
+
+		if !idRegex.MatchString(track.Id) {
+			v.addError(key+".id", "track[%d] id %q is not valid", i, track.Id)
+		}
+
+		if strings.TrimSpace(track.Name) == "" {
+			v.addError(key+".name", "track[%d] name is required", i)
+		}
+
+		if track.File == "" {
+			v.addError(key+".file", "track[%d] file is required", i)
+		} else {
+			_, err := os.Stat(track.File)
+			if err != nil {
+				v.addError(key+".file", "track[%d] file %q doesn't exist", i, track.File)
+			}
+
+			if other, exists := files[track.File]; exists {
+				otherTrack := &metadata.Tracks[other]
+				if !track.HasRange() || !otherTrack.HasRange() {
+					v.addError(key+".file", "track[%d] uses the same file as track[%d] without a range", i, other)
+				}
+			} else {
+				files[track.File] = i
+			}
+		}
+
+		if track.Start < 0 {
+			v.addError(key+".start", "track[%d] start %v is not valid", i, track.Start)
+		}
+
+		if track.End < 0 || (track.End != 0 && track.End <= track.Start) {
+			v.addError(key+".end", "track[%d] end %v needs to be after the start", i, track.End)
+		}
+
+		v.validateReleaseDate(key+".releaseDate", fmt.Sprintf("track[%d] release date", i), track.ReleaseDate, track.Year)
+		v.validateReleaseDate(key+".originalReleaseDate", fmt.Sprintf("track[%d] original release date", i), track.OriginalReleaseDate, 0)
+
+		if track.Disc < 0 {
+			v.addError(key+".disc", "track[%d] disc %d is not valid", i, track.Disc)
+		}
+
+		if track.DiscSubtitle != "" && track.Disc == 0 {
+			v.addError(key+".discSubtitle", "track[%d] has a disc subtitle but no disc", i)
+		}
+
+		if track.Number != 0 {
+			disc := track.Disc
+			if disc == 0 {
+				disc = 1
+			}
+
+			n := discNumber{disc, track.Number}
+			if other, exists := numbers[n]; exists {
+				v.addError(key+".number", "track[%d] has the same number (%d) as track[%d] on disc %d", i, track.Number, other, disc)
+			} else {
+				numbers[n] = i
+			}
+		}
+
+		for j, artist := range track.Artists {
+			if strings.TrimSpace(artist) == "" {
+				v.addError(key+".artists", "track[%d] artist[%d] is empty", i, j)
+			}
+		}
+	}
+}
+
+// decodeArtistMetadata decodes the artist.toml and validates the result,
+// unknown keys and invalid values is returned as ValidationErrors
+func decodeArtistMetadata(file string, data []byte, dir string) (ArtistMetadata, error) {
+	var metadata ArtistMetadata
+
+	v := validator{
+		file: file,
+	}
+
+	err := v.decode(data, &metadata)
+	if err != nil {
+		return ArtistMetadata{}, err
+	}
+
+	if metadata.Picture != "" {
+		metadata.Picture = path.Join(dir, metadata.Picture)
+	}
+
+	v.validateArtist(&metadata)
+
+	if len(v.errs) > 0 {
+		return ArtistMetadata{}, v.errs
+	}
+
+	return metadata, nil
+}
+
+func (v *validator) validateArtist(metadata *ArtistMetadata) {
+	if strings.TrimSpace(metadata.Name) == "" {
+		v.addError("name", "artist name is required")
+	}
+
+	if metadata.Picture != "" {
+		_, err := os.Stat(metadata.Picture)
+		if err != nil {
+			v.addError("picture", "picture %q doesn't exist", metadata.Picture)
+		}
+	}
+
+	for i, tag := range metadata.Tags {
+		if strings.TrimSpace(tag) == "" {
+			v.addError("tags", "tag[%d] is empty", i)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFindKeyPositions(t *testing.T) {
+	doc := `[album]
+id = "album"
+name = "Album"
+
+[[tracks]]
+name = "First"
+
+[[tracks]]
+  name = "Second"
+`
+
+	positions := findKeyPositions([]byte(doc))
+
+	tests := []struct {
+		key    string
+		line   int
+		column int
+	}{
+		{key: "album", line: 1, column: 2},
+		{key: "album.id", line: 2, column: 1},
+		{key: "album.name", line: 3, column: 1},
+		{key: "tracks[0]", line: 5, column: 3},
+		{key: "tracks[0].name", line: 6, column: 1},
+		{key: "tracks[1]", line: 8, column: 3},
+		{key: "tracks[1].name", line: 9, column: 3},
+	}
+
+	for i, test := range tests {
+		pos, exists := positions[test.key]
+		if !exists {
+			t.Errorf("Test %d Failed: (\"%s\") Expected a position", i, test.key)
+			continue
+		}
+
+		if pos.line != test.line || pos.column != test.column {
+			t.Errorf("Test %d Failed: (\"%s\") Expected %d:%d got %d:%d", i, test.key, test.line, test.column, pos.line, pos.column)
+		}
+	}
+
+	if _, exists := positions["tracks[2]"]; exists {
+		t.Errorf("Expected no position for tracks[2]")
+	}
+}
+
+func TestAddErrorParentFallback(t *testing.T) {
+	v := validator{
+		file: "album.toml",
+		positions: map[string]keyPosition{
+			"tracks[1]":      {line: 8, column: 3},
+			"tracks[1].name": {line: 9, column: 1},
+		},
+	}
+
+	tests := []struct {
+		key  string
+		line int
+	}{
+		{key: "tracks[1].name", line: 9},
+		{key: "tracks[1].artists", line: 8},
+		{key: "tracks[1].artists[2]", line: 8},
+		{key: "tracks[0].name", line: 0},
+		{key: "album.id", line: 0},
+	}
+
+	for i, test := range tests {
+		v.errs = nil
+		v.addError(test.key, "error")
+
+		if len(v.errs) != 1 {
+			t.Fatalf("Test %d Failed: (\"%s\") Expected 1 error got %d", i, test.key, len(v.errs))
+		}
+
+		err := v.errs[0]
+		if err.Line != test.line {
+			t.Errorf("Test %d Failed: (\"%s\") Expected line %d got %d", i, test.key, test.line, err.Line)
+		}
+
+		if err.File != "album.toml" || err.Message != "error" {
+			t.Errorf("Test %d Failed: (\"%s\") Unexpected error %+v", i, test.key, err)
+		}
+	}
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"a.flac", "b.flac"} {
+		err := os.WriteFile(path.Join(dir, name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const header = `[album]
+id = "album"
+name = "Album"
+artists = ["Artist"]
+`
+
+	track := func(id, file, extra string) string {
+		return "\n[[tracks]]\nid = \"" + id + "\"\nname = \"" + id + "\"\nfile = \"" + file + "\"\n" + extra
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+
+		// err is a part of the expected error message, empty if the
+		// document is valid
+		err  string
+		line int
+	}{
+		{
+			name: "valid",
+			doc:  header + track("t1", "a.flac", "number = 1\n") + track("t2", "b.flac", "number = 2\n"),
+		},
+		{
+			name: "same number on the same disc",
+			doc:  header + track("t1", "a.flac", "number = 1\n") + track("t2", "b.flac", "number = 1\n"),
+			err:  "has the same number (1) as track[0] on disc 1",
+			line: 16,
+		},
+		{
+			name: "same number on different discs",
+			doc:  header + track("t1", "a.flac", "number = 1\ndisc = 1\n") + track("t2", "b.flac", "number = 1\ndisc = 2\n"),
+		},
+		{
+			name: "no disc is disc 1",
+			doc:  header + track("t1", "a.flac", "number = 1\n") + track("t2", "b.flac", "number = 1\ndisc = 1\n"),
+			err:  "has the same number (1) as track[0] on disc 1",
+			line: 16,
+		},
+		{
+			name: "same file without range",
+			doc:  header + track("t1", "a.flac", "start = 0.0\nend = 10.0\n") + track("t2", "a.flac", ""),
+			err:  "uses the same file as track[0] without a range",
+			line: 16,
+		},
+		{
+			name: "same file with ranges",
+			doc:  header + track("t1", "a.flac", "end = 10.0\n") + track("t2", "a.flac", "start = 10.0\n"),
+		},
+		{
+			name: "end before start",
+			doc:  header + track("t1", "a.flac", "start = 10.0\nend = 5.0\n"),
+			err:  "end 5 needs to be after the start",
+			line: 11,
+		},
+		{
+			name: "missing file",
+			doc:  header + track("t1", "missing.flac", ""),
+			err:  "file \"" + path.Join(dir, "missing.flac") + "\" doesn't exist",
+			line: 9,
+		},
+		{
+			name: "unknown key",
+			doc:  header + "year2 = 2020\n",
+			err:  "unknown key \"album.year2\"",
+			line: 5,
+		},
+		{
+			name: "syntax error",
+			doc:  header + "name = \n",
+			err:  "toml:",
+			line: 5,
+		},
+		{
+			name: "wrong type",
+			doc:  "[album]\nid = 1\n",
+			err:  "toml:",
+			line: 2,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := decodeMetadata("album.toml", []byte(test.doc), dir)
+
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: Expected no error got %v", test.name, err)
+			}
+
+			continue
+		}
+
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: Expected ValidationErrors got %v", test.name, err)
+			continue
+		}
+
+		found := false
+		for _, e := range errs {
+			if !strings.Contains(e.Message, test.err) {
+				continue
+			}
+
+			found = true
+
+			if e.File != "album.toml" || e.Line != test.line {
+				t.Errorf("%s: Expected album.toml:%d got %s:%d", test.name, test.line, e.File, e.Line)
+			}
+		}
+
+		if !found {
+			t.Errorf("%s: Expected error containing %q got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var releaseDateRegex = regexp.MustCompile(`^(\d{4})(?:-(\d{1,2})(?:-(\d{1,2}))?)?$`)
+var releaseDatePrefixRegex = regexp.MustCompile(`^\d{4}(?:-\d{1,2}(?:-\d{1,2})?)?`)
+
+// NormalizeReleaseDate validates a full or partial release date (YYYY,
+// YYYY-MM or YYYY-MM-DD) and returns it zero padded, the dates can then be
+// compared as strings
+func NormalizeReleaseDate(s string) (string, error) {
+	match := releaseDateRegex.FindStringSubmatch(s)
+	if match == nil {
+		return "", fmt.Errorf("%q is not a valid date (YYYY, YYYY-MM or YYYY-MM-DD)", s)
+	}
+
+	year, _ := strconv.Atoi(match[1])
+	if match[2] == "" {
+		return fmt.Sprintf("%04d", year), nil
+	}
+
+	month, _ := strconv.Atoi(match[2])
+	if month < 1 || month > 12 {
+		return "", fmt.Errorf("%q has an invalid month", s)
+	}
+
+	if match[3] == "" {
+		return fmt.Sprintf("%04d-%02d", year, month), nil
+	}
+
+	day, _ := strconv.Atoi(match[3])
+
+	// NOTE: Day 0 of the next month is the last day of the month
+	lastDay := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day < 1 || day > lastDay {
+		return "", fmt.Errorf("%q has an invalid day", s)
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d", year, month, day), nil
+}
+
+// ParseReleaseDateTag returns the release date from the start of a date
+// tag, tags like "2020-05-10T00:00:00" returns "2020-05-10". Returns an
+// empty string if the tag doesn't start with a valid date.
+func ParseReleaseDateTag(s string) string {
+	date, err := NormalizeReleaseDate(releaseDatePrefixRegex.FindString(s))
+	if err != nil {
+		return ""
+	}
+
+	return date
+}
+
+// ReleaseDateYear returns the year of a normalized release date, 0 if the
+// date is empty
+func ReleaseDateYear(date string) int64 {
+	if len(date) < 4 {
+		return 0
+	}
+
+	year, _ := strconv.ParseInt(date[:4], 10, 64)
+	return year
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentHashAudioPrefix is used for hashes of the audio stream
+	ContentHashAudioPrefix = "audio-sha256:"
+	// ContentHashFilePrefix is used for hashes of the whole file
+	ContentHashFilePrefix = "file-sha256:"
+)
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
+
+// HashAudioStream hashes the encoded packets of the first audio stream,
+// the tags and the embedded cover art isn't included so a retagged copy
+// of the file has the same hash. Start and end is the range inside the
+// file in seconds, 0 is not set.
+func HashAudioStream(file string, start, end float64) (string, error) {
+	var args []string
+
+	if start > 0 {
+		args = append(args, "-ss", formatSeconds(start))
+	}
+
+	args = append(args, "-i", file)
+
+	if end > 0 {
+		args = append(args, "-t", formatSeconds(end-start))
+	}
+
+	args = append(args, "-hide_banner", "-nostats", "-map", "0:a:0", "-c", "copy", "-f", "hash", "-hash", "sha256", "-")
+
+	var stdout bytes.Buffer
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = &stdout
+
+	err := cmd.Run()
+	if err != nil {
+		return "", err
+	}
+
+	_, hash, found := strings.Cut(strings.TrimSpace(stdout.String()), "=")
+	if !found || hash == "" {
+		return "", errors.New("ffmpeg returned no hash")
+	}
+
+	return hash, nil
+}
+
+func HashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ContentHash returns the content hash of a track, the audio stream is
+// hashed and if ffmpeg can't read the file the whole file is hashed
+// instead. Tracks with a range can't fallback to the file hash because
+// the other tracks inside the file would get the same hash.
+func ContentHash(file string, start, end float64) (string, error) {
+	hash, err := HashAudioStream(file, start, end)
+	if err == nil {
+		return ContentHashAudioPrefix + hash, nil
+	}
+
+	if start > 0 || end > 0 {
+		return "", err
+	}
+
+	hash, err = HashFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return ContentHashFilePrefix + hash, nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// ReplayGainReference is the loudness in LUFS used as the target for the
+// gain, the same reference as ReplayGain 2.0
+const ReplayGainReference = -18.0
+
+var ebur128IntegratedRegex = regexp.MustCompile(`I:\s+(-?[\d.]+|-inf) LUFS`)
+var ebur128PeakRegex = regexp.MustCompile(`Peak:\s+(-?[\d.]+|-inf) dBFS`)
+
+type Loudness struct {
+	// Integrated is the integrated loudness in LUFS
+	Integrated float64
+	// TruePeak is the true peak in dBFS
+	TruePeak float64
+}
+
+// Gain returns the ReplayGain gain in dB
+func (l Loudness) Gain() float64 {
+	return ReplayGainReference - l.Integrated
+}
+
+// Peak returns the true peak as a linear amplitude, 1.0 is full scale
+func (l Loudness) Peak() float64 {
+	return DecibelToLinear(l.TruePeak)
+}
+
+func DecibelToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func LinearToDecibel(v float64) float64 {
+	return 20 * math.Log10(v)
+}
+
+func lastSubmatch(re *regexp.Regexp, s string) (string, bool) {
+	matches := re.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	return matches[len(matches)-1][1], true
+}
+
+func parseLoudnessValue(s string) float64 {
+	if s == "-inf" {
+		return math.Inf(-1)
+	}
+
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// ParseEbur128Summary parses the summary printed by the ffmpeg ebur128
+// filter (with peak=true), the summary is printed after the per frame log
+// so the last values is used
+func ParseEbur128Summary(output string) (Loudness, error) {
+	integrated, ok := lastSubmatch(ebur128IntegratedRegex, output)
+	if !ok {
+		return Loudness{}, errors.New("ebur128 summary is missing the integrated loudness")
+	}
+
+	peak, ok := lastSubmatch(ebur128PeakRegex, output)
+	if !ok {
+		return Loudness{}, errors.New("ebur128 summary is missing the true peak")
+	}
+
+	res := Loudness{
+		Integrated: parseLoudnessValue(integrated),
+		TruePeak:   parseLoudnessValue(peak),
+	}
+
+	// NOTE: Silent tracks has no integrated loudness, the gain would be
+	// infinite
+	if math.IsInf(res.Integrated, 0) {
+		return Loudness{}, errors.New("track is silent")
+	}
+
+	return res, nil
+}
+
+// AlbumLoudness combines the loudness of the tracks weighted by the
+// duration, the peak is the highest peak of the tracks
+func AlbumLoudness(tracks []Loudness, durations []float64) Loudness {
+	var energy, total float64
+	peak := math.Inf(-1)
+
+	for i, t := range tracks {
+		d := durations[i]
+		if d <= 0 {
+			d = 1
+		}
+
+		energy += d * math.Pow(10, t.Integrated/10)
+		total += d
+
+		peak = max(peak, t.TruePeak)
+	}
+
+	if total == 0 {
+		return Loudness{}
+	}
+
+	return Loudness{
+		Integrated: 10 * math.Log10(energy/total),
+		TruePeak:   peak,
+	}
+}
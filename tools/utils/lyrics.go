@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var lrcTagRegex = regexp.MustCompile(`^\[([^\]]*)\]`)
+var lrcTimeRegex = regexp.MustCompile(`^(\d+):(\d{1,2})(?:[.:](\d{1,3}))?$`)
+
+type LyricsLine struct {
+	// Time is the start of the line in seconds
+	Time float64
+	Text string
+}
+
+type Lyrics struct {
+	// Synced is set if the lyrics contains time stamps (LRC)
+	Synced bool
+	Lines  []LyricsLine
+
+	// Text is the lyrics without the time stamps and tags
+	Text string
+}
+
+func parseLrcTime(s string) (float64, bool) {
+	match := lrcTimeRegex.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+
+	minutes, _ := strconv.ParseInt(match[1], 10, 64)
+	seconds, _ := strconv.ParseInt(match[2], 10, 64)
+
+	res := float64(minutes*60 + seconds)
+
+	if match[3] != "" {
+		// NOTE: The fraction can be hundredths ([mm:ss.xx]) or
+		// milliseconds ([mm:ss.xxx])
+		fraction, _ := strconv.ParseInt(match[3], 10, 64)
+		res += float64(fraction) / float64(pow10(len(match[3])))
+	}
+
+	return res, true
+}
+
+func pow10(n int) int64 {
+	res := int64(1)
+	for i := 0; i < n; i++ {
+		res *= 10
+	}
+
+	return res
+}
+
+// ParseLyrics parses plain or LRC lyrics, lines with multiple time stamps
+// is repeated for every time stamp and the offset tag is applied
+func ParseLyrics(s string) Lyrics {
+	s = strings.TrimPrefix(s, "\ufeff")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	var res Lyrics
+	var text []string
+
+	var offset float64
+
+	for _, line := range strings.Split(s, "\n") {
+		var times []float64
+		isTag := false
+
+		for {
+			match := lrcTagRegex.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+
+			if t, ok := parseLrcTime(match[1]); ok {
+				times = append(times, t)
+			} else if key, value, found := strings.Cut(match[1], ":"); found {
+				// NOTE: Tags like [ar:Artist], only the offset is used
+				isTag = true
+
+				if strings.TrimSpace(strings.ToLower(key)) == "offset" {
+					ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+					if err == nil {
+						offset = float64(ms) / 1000
+					}
+				}
+			} else {
+				break
+			}
+
+			line = line[len(match[0]):]
+		}
+
+		line = strings.TrimSpace(line)
+
+		if len(times) == 0 {
+			if isTag {
+				continue
+			}
+
+			text = append(text, line)
+			continue
+		}
+
+		res.Synced = true
+		text = append(text, line)
+
+		for _, t := range times {
+			res.Lines = append(res.Lines, LyricsLine{
+				Time: t,
+				Text: line,
+			})
+		}
+	}
+
+	if res.Synced {
+		// NOTE: A positive offset shows the lines earlier
+		for i := range res.Lines {
+			res.Lines[i].Time = max(res.Lines[i].Time-offset, 0)
+		}
+
+		sort.SliceStable(res.Lines, func(i, j int) bool {
+			return res.Lines[i].Time < res.Lines[j].Time
+		})
+	}
+
+	res.Text = strings.TrimSpace(strings.Join(text, "\n"))
+
+	return res
+}
+
+func IsValidLyricsExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".lrc", ".txt":
+		return true
+	}
+
+	return false
+}
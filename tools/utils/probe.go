@@ -19,6 +19,40 @@ func convertMapKeysToLowercase(m map[string]any) map[string]any {
 	return res
 }
 
+// probeMediaType returns the media type from the format and the codec
+// reported by ffprobe
+func probeMediaType(formatName, codecName string) types.MediaType {
+	switch formatName {
+	case "flac":
+		return types.MediaTypeFlac
+	case "ogg":
+		switch codecName {
+		case "opus":
+			return types.MediaTypeOggOpus
+		case "vorbis":
+			return types.MediaTypeOggVorbis
+		}
+	case "mp3":
+		return types.MediaTypeMp3
+	case "aac":
+		return types.MediaTypeAac
+	case "wav":
+		return types.MediaTypeWav
+	case "wv":
+		return types.MediaTypeWavPack
+	case "mov,mp4,m4a,3gp,3g2,mj2":
+		// NOTE: The MP4 container is used by both AAC (.m4a) and ALAC
+		switch codecName {
+		case "aac":
+			return types.MediaTypeM4a
+		case "alac":
+			return types.MediaTypeAlac
+		}
+	}
+
+	return types.MediaTypeUnknown
+}
+
 type TrackInfo struct {
 	Path string
 
@@ -30,6 +64,21 @@ type ProbeResult struct {
 	Tags      ffprobe.Tags
 	MediaType types.MediaType
 	Duration  float64
+
+	// NOTE: The technical properties is 0 if unknown, lossy codecs has no
+	// bit depth
+	SampleRate int64
+	BitDepth   int64
+	Channels   int64
+	// BitRate is in bits per second
+	BitRate  int64
+	Codec    string
+	FileSize int64
+}
+
+func parseProbeInt(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
 }
 
 func ProbeTrack(filepath string) (ProbeResult, error) {
@@ -61,25 +110,28 @@ func ProbeTrack(filepath string) (ProbeResult, error) {
 		return ProbeResult{}, err
 	}
 
-	mediaType := types.MediaTypeUnknown
+	mediaType := probeMediaType(probe.Format.FormatName, audioStream.CodecName)
 
-	// TODO(patrik): Move to helper
-	// TODO(patrik): Add more types
-	switch probe.Format.FormatName {
-	case "flac":
-		mediaType = types.MediaTypeFlac
-	case "ogg":
-		switch audioStream.CodecName {
-		case "opus":
-			mediaType = types.MediaTypeOggOpus
-		case "vorbis":
-			mediaType = types.MediaTypeOggVorbis
-		}
+	bitDepth := parseProbeInt(audioStream.BitsPerRawSample)
+	if bitDepth == 0 {
+		// NOTE: PCM (wav) only sets the bits per sample
+		bitDepth = int64(audioStream.BitsPerSample)
+	}
+
+	bitRate := parseProbeInt(audioStream.BitRate)
+	if bitRate == 0 {
+		bitRate = parseProbeInt(probe.Format.BitRate)
 	}
 
 	return ProbeResult{
-		Tags:      tags,
-		MediaType: mediaType,
-		Duration:  duration,
+		Tags:       tags,
+		MediaType:  mediaType,
+		Duration:   duration,
+		SampleRate: parseProbeInt(audioStream.SampleRate),
+		BitDepth:   bitDepth,
+		Channels:   int64(audioStream.Channels),
+		BitRate:    bitRate,
+		Codec:      audioStream.CodecName,
+		FileSize:   parseProbeInt(probe.Format.Size),
 	}, nil
 }
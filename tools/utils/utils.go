@@ -23,6 +23,8 @@ var CreateTrackMediaId = createIdGenerator(32)
 
 var CreateApiTokenId = createIdGenerator(32)
 
+var CreateSyncRunId = createIdGenerator(16)
+
 func createIdGenerator(length int) func() string {
 	res, err := cuid2.Init(cuid2.WithLength(length))
 	if err != nil {
@@ -135,14 +137,20 @@ func IsValidImageExt(ext string) bool {
 	return false
 }
 
-// TODO(patrik): Update this
 var validExts []string = []string{
 	".wav",
 	".flac",
 	".opus",
+	".ogg",
+	".mp3",
+	".aac",
+	".m4a",
+	".wv",
 }
 
 func IsValidTrackExt(ext string) bool {
+	ext = strings.ToLower(ext)
+
 	for _, valid := range validExts {
 		if valid == ext {
 			return true
@@ -151,3 +159,7 @@ func IsValidTrackExt(ext string) bool {
 
 	return false
 }
+
+func IsValidCueExt(ext string) bool {
+	return strings.ToLower(ext) == ".cue"
+}
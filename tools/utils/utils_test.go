@@ -50,3 +50,112 @@ func TestExtract(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLyrics(t *testing.T) {
+	lrc := "[ar:Artist]\n[offset:500]\n[00:12.50]First line\n[00:05.00][00:20.00]Chorus\n"
+
+	lyrics := utils.ParseLyrics(lrc)
+	if !lyrics.Synced {
+		t.Fatalf("Expected synced lyrics")
+	}
+
+	expected := []utils.LyricsLine{
+		{Time: 4.5, Text: "Chorus"},
+		{Time: 12, Text: "First line"},
+		{Time: 19.5, Text: "Chorus"},
+	}
+
+	if len(lyrics.Lines) != len(expected) {
+		t.Fatalf("Expected %d lines got %d", len(expected), len(lyrics.Lines))
+	}
+
+	for i, line := range lyrics.Lines {
+		if line != expected[i] {
+			t.Errorf("Line %d: Expected %+v got %+v", i, expected[i], line)
+		}
+	}
+
+	if lyrics.Text != "First line\nChorus" {
+		t.Errorf("Unexpected text %q", lyrics.Text)
+	}
+
+	plain := utils.ParseLyrics("Hello\r\n\r\nWorld\r\n")
+	if plain.Synced || plain.Text != "Hello\n\nWorld" {
+		t.Errorf("Unexpected plain lyrics %+v", plain)
+	}
+}
+
+func TestParseEbur128Summary(t *testing.T) {
+	output := `[Parsed_ebur128_0 @ 0x1] t: 0.1       TARGET:-23 LUFS    M:-120.7 S:-120.7     I: -70.0 LUFS       LRA:   0.0 LU  FTPK: -inf dBFS  TPK: -inf dBFS
+[Parsed_ebur128_0 @ 0x1] Summary:
+
+  Integrated loudness:
+    I:         -12.4 LUFS
+    Threshold: -22.7 LUFS
+
+  Loudness range:
+    LRA:         5.1 LU
+    Threshold:  -32.7 LUFS
+    LRA low:    -16.8 LUFS
+    LRA high:   -11.7 LUFS
+
+  True peak:
+    Peak:        0.8 dBFS
+`
+
+	loudness, err := utils.ParseEbur128Summary(output)
+	if err != nil {
+		t.Fatalf("Failed to parse summary: %v", err)
+	}
+
+	if loudness.Integrated != -12.4 || loudness.TruePeak != 0.8 {
+		t.Fatalf("Unexpected loudness %+v", loudness)
+	}
+
+	if gain := loudness.Gain(); gain < -5.61 || gain > -5.59 {
+		t.Errorf("Expected gain -5.6 got %v", gain)
+	}
+
+	_, err = utils.ParseEbur128Summary("I: -inf LUFS\nPeak: -inf dBFS\n")
+	if err == nil {
+		t.Errorf("Expected error for silent track")
+	}
+}
+
+func TestNormalizeReleaseDate(t *testing.T) {
+	type test struct {
+		s        string
+		expected string
+		err      bool
+	}
+
+	tests := []test{
+		{s: "2020", expected: "2020"},
+		{s: "2020-5", expected: "2020-05"},
+		{s: "2020-05-09", expected: "2020-05-09"},
+		{s: "2020-02-29", expected: "2020-02-29"},
+		{s: "2021-02-29", err: true},
+		{s: "2020-13", err: true},
+		{s: "20", err: true},
+		{s: "2020/05/09", err: true},
+	}
+
+	for i, test := range tests {
+		date, err := utils.NormalizeReleaseDate(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("Test %d Failed: (\"%s\") Expected error got %q", i, test.s, date)
+			}
+
+			continue
+		}
+
+		if err != nil || date != test.expected {
+			t.Errorf("Test %d Failed: (\"%s\") Expected %q got %q (%v)", i, test.s, test.expected, date, err)
+		}
+	}
+
+	if date := utils.ParseReleaseDateTag("2020-05-09T00:00:00Z"); date != "2020-05-09" {
+		t.Errorf("Expected tag date 2020-05-09 got %q", date)
+	}
+}
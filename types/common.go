@@ -1,6 +1,9 @@
 package types
 
-import "path"
+import (
+	"path"
+	"strings"
+)
 
 type MediaType string
 
@@ -11,10 +14,16 @@ const (
 	MediaTypeOggVorbis MediaType = "ogg-vorbis"
 	MediaTypeMp3       MediaType = "mp3"
 	MediaTypeAac       MediaType = "aac"
+	MediaTypeM4a       MediaType = "m4a"
+	MediaTypeAlac      MediaType = "alac"
+	MediaTypeWav       MediaType = "wav"
+	MediaTypeWavPack   MediaType = "wavpack"
 )
 
+// GetMediaTypeFromExt returns the media type used for files with the
+// extension, .m4a is AAC because ALAC uses the same extension
 func GetMediaTypeFromExt(ext string) MediaType {
-	switch ext {
+	switch strings.ToLower(ext) {
 	case ".flac":
 		return MediaTypeFlac
 	case ".opus":
@@ -25,6 +34,12 @@ func GetMediaTypeFromExt(ext string) MediaType {
 		return MediaTypeMp3
 	case ".aac":
 		return MediaTypeAac
+	case ".m4a":
+		return MediaTypeM4a
+	case ".wav":
+		return MediaTypeWav
+	case ".wv":
+		return MediaTypeWavPack
 	}
 
 	return MediaTypeUnknown
@@ -42,11 +57,41 @@ func (m MediaType) ToExt() (string, bool) {
 		return ".mp3", true
 	case MediaTypeAac:
 		return ".aac", true
+	case MediaTypeM4a, MediaTypeAlac:
+		return ".m4a", true
+	case MediaTypeWav:
+		return ".wav", true
+	case MediaTypeWavPack:
+		return ".wv", true
 	}
 
 	return "", false
 }
 
+// ContentType returns the mime type used when serving the media
+func (m MediaType) ContentType() string {
+	switch m {
+	case MediaTypeFlac:
+		return "audio/flac"
+	case MediaTypeOggOpus:
+		return "audio/ogg; codecs=opus"
+	case MediaTypeOggVorbis:
+		return "audio/ogg; codecs=vorbis"
+	case MediaTypeMp3:
+		return "audio/mpeg"
+	case MediaTypeAac:
+		return "audio/aac"
+	case MediaTypeM4a, MediaTypeAlac:
+		return "audio/mp4"
+	case MediaTypeWav:
+		return "audio/wav"
+	case MediaTypeWavPack:
+		return "audio/x-wavpack"
+	}
+
+	return "application/octet-stream"
+}
+
 func (m MediaType) IsValid() bool {
 	switch m {
 	case MediaTypeFlac:
@@ -59,6 +104,95 @@ func (m MediaType) IsValid() bool {
 		return true
 	case MediaTypeAac:
 		return true
+	case MediaTypeM4a:
+		return true
+	case MediaTypeAlac:
+		return true
+	case MediaTypeWav:
+		return true
+	case MediaTypeWavPack:
+		return true
+	}
+
+	return false
+}
+
+// NormalizeMode selects the gain used for loudness normalized transcodes
+type NormalizeMode string
+
+const (
+	NormalizeModeTrack NormalizeMode = "track"
+	NormalizeModeAlbum NormalizeMode = "album"
+)
+
+func (m NormalizeMode) IsValid() bool {
+	switch m {
+	case NormalizeModeTrack:
+		return true
+	case NormalizeModeAlbum:
+		return true
+	}
+
+	return false
+}
+
+// ReleaseType is the kind of release an album is
+type ReleaseType string
+
+const (
+	ReleaseTypeAlbum       ReleaseType = "album"
+	ReleaseTypeSingle      ReleaseType = "single"
+	ReleaseTypeEP          ReleaseType = "ep"
+	ReleaseTypeCompilation ReleaseType = "compilation"
+	ReleaseTypeLive        ReleaseType = "live"
+	ReleaseTypeSoundtrack  ReleaseType = "soundtrack"
+	ReleaseTypeRemix       ReleaseType = "remix"
+	ReleaseTypeMixtape     ReleaseType = "mixtape"
+	ReleaseTypeDemo        ReleaseType = "demo"
+	ReleaseTypeOther       ReleaseType = "other"
+)
+
+var ReleaseTypes = []ReleaseType{
+	ReleaseTypeAlbum,
+	ReleaseTypeSingle,
+	ReleaseTypeEP,
+	ReleaseTypeCompilation,
+	ReleaseTypeLive,
+	ReleaseTypeSoundtrack,
+	ReleaseTypeRemix,
+	ReleaseTypeMixtape,
+	ReleaseTypeDemo,
+	ReleaseTypeOther,
+}
+
+// ParseReleaseType returns the release type from names like "EP" or
+// "Compilation", returns false if the name is unknown
+func ParseReleaseType(s string) (ReleaseType, bool) {
+	t := ReleaseType(strings.ToLower(strings.TrimSpace(s)))
+
+	switch t {
+	case "ost":
+		t = ReleaseTypeSoundtrack
+	case "dj-mix":
+		t = ReleaseTypeRemix
+	case "mixtape/street":
+		t = ReleaseTypeMixtape
+	case "broadcast", "audiobook", "spokenword", "interview", "audio drama":
+		t = ReleaseTypeOther
+	}
+
+	if !t.IsValid() {
+		return "", false
+	}
+
+	return t, true
+}
+
+func (t ReleaseType) IsValid() bool {
+	for _, v := range ReleaseTypes {
+		if t == v {
+			return true
+		}
 	}
 
 	return false